@@ -0,0 +1,121 @@
+package configape
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ByteSize is a number of bytes that can be set with a unit, eg 512KiB or 10MB. KB, MB, GB, TB
+// and PB are powers of 1000, and KiB, MiB, GiB, TiB and PiB (or just K, M, G, T and P) are powers
+// of 1024. Without a unit it is a number of bytes.
+type ByteSize uint64
+
+const (
+	KiB ByteSize = 1 << (10 * (iota + 1))
+	MiB
+	GiB
+	TiB
+	PiB
+)
+
+const (
+	KB ByteSize = 1000
+	MB          = KB * 1000
+	GB          = MB * 1000
+	TB          = GB * 1000
+	PB          = TB * 1000
+)
+
+// The units, largest first, in the order String prefers them
+var byteSizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"PiB", PiB}, {"TiB", TiB}, {"GiB", GiB}, {"MiB", MiB}, {"KiB", KiB},
+	{"PB", PB}, {"TB", TB}, {"GB", GB}, {"MB", MB}, {"KB", KB},
+}
+
+// The units that can be parsed, in lower case
+var byteSizeParseUnits = map[string]ByteSize{
+	"": 1, "b": 1,
+	"k": KiB, "kib": KiB, "kb": KB,
+	"m": MiB, "mib": MiB, "mb": MB,
+	"g": GiB, "gib": GiB, "gb": GB,
+	"t": TiB, "tib": TiB, "tb": TB,
+	"p": PiB, "pib": PiB, "pb": PB,
+}
+
+// ParseByteSize parses a size such as 512KiB, 10MB, 1.5G or 4096. The unit is case insensitive
+// and can be separated from the number by a space.
+func ParseByteSize(str string) (ByteSize, error) {
+	str = strings.TrimSpace(str)
+	split := strings.IndexFunc(str, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if split == -1 {
+		split = len(str)
+	}
+	number, unit := str[:split], strings.TrimSpace(str[split:])
+	size, ok := byteSizeParseUnits[strings.ToLower(unit)]
+	if !ok || number == "" {
+		return 0, fmt.Errorf("invalid byte size: %s", str)
+	}
+	if !strings.Contains(number, ".") {
+		n, err := strconv.ParseUint(number, 10, 64)
+		if err != nil || n > math.MaxUint64/uint64(size) {
+			return 0, fmt.Errorf("invalid byte size: %s", str)
+		}
+		return ByteSize(n) * size, nil
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte size: %s", str)
+	}
+	f = math.Round(f * float64(size))
+	if f >= math.MaxUint64 {
+		return 0, fmt.Errorf("invalid byte size: %s", str)
+	}
+	return ByteSize(f), nil
+}
+
+// String returns the size in the largest unit that it is a whole number of, eg 1536 is 1536B
+// (not 1.5KiB), 2048 is 2KiB and 3000000 is 3MB.
+func (b ByteSize) String() string {
+	if b == 0 {
+		return "0B"
+	}
+	for _, unit := range byteSizeUnits {
+		if b%unit.size == 0 {
+			return strconv.FormatUint(uint64(b/unit.size), 10) + unit.name
+		}
+	}
+	return strconv.FormatUint(uint64(b), 10) + "B"
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+// UnmarshalJSON accepts a number of bytes as well as a string with a unit.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	str := string(data)
+	if strings.HasPrefix(str, `"`) {
+		var err error
+		str, err = strconv.Unquote(str)
+		if err != nil {
+			return err
+		}
+	}
+	return b.UnmarshalText([]byte(str))
+}
@@ -0,0 +1,66 @@
+package configape
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]ByteSize{
+		"0":        0,
+		"4096":     4096,
+		"512KiB":   512 * KiB,
+		"512k":     512 * KiB,
+		"10MB":     10 * MB,
+		"10 mb":    10 * MB,
+		"1.5GiB":   GiB + 512*MiB,
+		"2TB":      2 * TB,
+		"1PiB":     PiB,
+		"100B":     100,
+		" 3 G ":    3 * GiB,
+		"16383PiB": 16383 * PiB,
+	}
+	for str, want := range tests {
+		got, err := ParseByteSize(str)
+		if err != nil || got != want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, expected %d", str, got, err, want)
+		}
+	}
+	for _, str := range []string{"", "MB", "10XB", "-1KB", "1.2.3MB", "16385PiB", "99999999999999999999"} {
+		if _, err := ParseByteSize(str); err == nil {
+			t.Errorf("ParseByteSize(%q) expected an error", str)
+		}
+	}
+}
+
+func TestByteSizeString(t *testing.T) {
+	tests := map[ByteSize]string{
+		0:               "0B",
+		1536:            "1536B",
+		2 * KiB:         "2KiB",
+		3 * MB:          "3MB",
+		GiB:             "1GiB",
+		1024 * KB:       "1000KiB",
+		ByteSize(12345): "12345B",
+	}
+	for size, want := range tests {
+		if got := size.String(); got != want {
+			t.Errorf("%d.String() = %s, expected %s", uint64(size), got, want)
+		}
+	}
+}
+
+func TestByteSizeJSON(t *testing.T) {
+	var sizes []ByteSize
+	err := json.Unmarshal([]byte(`[1024, "2MiB"]`), &sizes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes[0] != KiB || sizes[1] != 2*MiB {
+		t.Errorf("expected a number and a string, got %v", sizes)
+	}
+	str, _ := json.Marshal(sizes)
+	if string(str) != `["1KiB","2MiB"]` {
+		t.Errorf("expected the sizes as strings, got %s", str)
+	}
+}
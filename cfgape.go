@@ -6,22 +6,32 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"text/template"
+	"time"
 )
 
 // Options on how Config Ape should work.
 type Options struct {
-	ConfigFilename         string // Name of the config file to use
-	ConfigFileType         string // The file type, defaults to json and determines the file extension.
-	EnvironmentPrefix      string // Prefix for environment variables, empty string defaults to CFG_, if you really want no prefix, set to ! (not recommended)
-	UseSingleDashArguments bool   // If set, then arguments are expected as "-foo bar" instead of "--foo bar" (not recommended)
+	ConfigFilename         string   // Name of the config file to use
+	ConfigFilenames        []string // Config files to load in order after ConfigFilename, later files override earlier ones. Missing files are skipped.
+	ConfigFileType         string   // The file type, defaults to json and determines the file extension.
+	EnvironmentPrefix      string   // Prefix for environment variables, empty string defaults to CFG_, if you really want no prefix, set to ! (not recommended)
+	UseSingleDashArguments bool     // If set, then arguments are expected as "-foo bar" instead of "--foo bar" (not recommended)
 
 	Help                         func(str string) // If set, then this function will be called when the help flag is set.
 	HelpHeader                   string           // Help text that is prefixed to the help output.
 	HelpFooter                   string           // Help text that is appended to the help output.
 	HelpWriter                   io.Writer        // Where to write the help output, defaults to os.Stderr
+	HelpWidth                    int              // The width to wrap the help to, defaults to the width of the terminal or 80.
+	HelpTemplate                 string           // A text/template rendered with a HelpModel to make the help, defaults to DefaultHelpTemplate.
+	HelpShowNames                bool             // If set, then the help shows the environment variable and config file key of each setting, which are required, and the settings that can't be set on the command line.
 	DisableHelpOnMissingRequired bool             // If set, then the help will not be printed if a required setting is missing.
 	DisableHelp                  bool             // Disable the help flag
 	DisableVersion               bool             // Disable the version flag
+	EnablePrintConfig            bool             // Enable the --print-config flag, which prints every setting, its value and where it came from.
+	EnableCompletion             bool             // Enable the --completion=bash|zsh|fish flag, which prints a shell completion script.
+	EnableGenerateConfig         bool             // Enable the --generate-config[=json|yaml|toml] flag, which prints a sample config file.
+	ExitOnHelp                   bool             // If set, then the program exits with status 0 after printing the help or version, rather than Apply returning ErrHelpRequested or ErrVersionRequested.
 
 	Name    string // Name of the program, used in the help output. Defaults to os.Args[0]
 	Version string // Version of the program, used in the help output. Defaults to "v0.0.0"
@@ -30,11 +40,17 @@ type Options struct {
 	DisableConfigFile  bool // Disable config file parsing
 	DisableCommandLine bool // Disable command line parsing
 
-	AllowUnknownConfigFileKeys bool // If set, then unknown keys in the config file will not cause an error.
+	WatchInterval               time.Duration            // How often Watch checks the config files for changes, defaults to 1 second.
+	AppendCommandLineConfigFile bool                     // If set, a config file given on the command line is loaded after the other config files rather than replacing them.
+	SaveSkipDefaults            bool                     // If set, Save leaves out the settings that have their default value.
+	SaveOmitSecrets             bool                     // If set, Save leaves out the secret settings.
+	AllowUnknownConfigFileKeys  bool                     // If set, then unknown keys in the config file will not cause an error.
+	Decoders                    map[string]ConfigDecoder // Additional config file formats keyed by file extension, these take precedence over RegisterFormat.
 
 	// For testing
 	cfgFileContents string
 	osArgs          []string
+	exit            func(code int) // Replaces os.Exit
 }
 
 // Internal state holder.
@@ -42,16 +58,29 @@ type cfgApe struct {
 	options   Options
 	cfg       interface{}
 	settings  cfgSettings
-	remaining []string // The remaining non option arguments.
+	remaining []string      // The remaining non option arguments.
+	commands  []*cfgSetting // The commands selected on the command line, outermost first.
+
+	helpTemplate *template.Template
+
+	printConfigRequested bool     // Set when --print-config is on the command line
+	configFiles          []string // The config files that were read, for Watch
+	reloading            bool     // Set when Watch is reloading the config, so nothing is printed
+	watching             bool     // Set by Watch, so the config files are recorded in fileStates
+	fileStates           map[string]watchState
 }
 
-// Apply the configuration to the provided cfg struct, using the options provided.
+// Apply the configuration to the provided cfg struct, using the options provided. The cfg can
+// also be a *Live[T].
 func Apply(cfg interface{}, options *Options) error {
 	c := cfgApe{}
 	return c.Apply(cfg, options)
 }
 
 func (c *cfgApe) Apply(cfg interface{}, options *Options) error {
+	if live, ok := cfg.(liveConfig); ok {
+		return c.applyLive(live, options)
+	}
 	if options == nil {
 		options = &Options{}
 	}
@@ -66,53 +95,46 @@ func (c *cfgApe) Apply(cfg interface{}, options *Options) error {
 	if err != nil {
 		return err
 	}
-	defaultCfgFile := c.options.ConfigFilename
-	if defaultCfgFile == "" {
-		defaultCfgFile = "config.json"
+	err = c.parseHelpTemplate()
+	if err != nil {
+		return err
 	}
-
 	// See if there is a config file specified on the command line
+	var cliCfgFile string
 	if !c.options.DisableCommandLine {
 		args := os.Args
 		if c.options.osArgs != nil {
 			args = c.options.osArgs
 		}
-		file, err := c.getCliConfigFile(args)
+		cliCfgFile, err = c.getCliConfigFile(args)
 		if err != nil {
 			return err
 		}
-		if file != "" {
-			defaultCfgFile = file
-		}
 	}
 
 	// Set defaults
 	err = c.settings.SetDefaults()
 	if err != nil {
-		return fmt.Errorf("error setting defaults: %s", err)
+		return err
 	}
-	// fmt.Println("After defaults")
-	// debugf("%+v\n", c.settings)
+
+	// All the problems found from here on are collected, so that they can all be fixed at once.
+	errs := &MultiError{}
 
 	if !c.options.DisableConfigFile {
-		// read the config file first.
-		err = c.parseConfigFile(defaultCfgFile)
-		if err != nil {
-			return err
+		// read the config files first.
+		c.configFiles = c.configFileChain(cliCfgFile)
+		if c.watching {
+			// Before they are read, so that a change while they are being read is noticed
+			c.fileStates = readWatchStates(c.configFiles)
 		}
+		errs.add(c.parseConfigFiles(c.configFiles, cliCfgFile))
 	}
-	// fmt.Println("After Config File")
-	// debugf("%+v\n", c.settings)
 
 	if !c.options.DisableEnviornment {
 		// Now we need to parse the environment variables
-		err = c.processEnvironment()
-		if err != nil {
-			return err
-		}
+		errs.add(c.processEnvironment())
 	}
-	// fmt.Println("After Environment")
-	// debugf("%+v\n", c.settings)
 
 	// Now we need to parse the command line
 	if !c.options.DisableCommandLine {
@@ -121,34 +143,64 @@ func (c *cfgApe) Apply(cfg interface{}, options *Options) error {
 		} else {
 			err = c.parseCommandLine(c.options.osArgs)
 		}
-		if err != nil {
+		if err == ErrHelpRequested || err == ErrVersionRequested || err == ErrCompletionRequested || err == ErrGenerateConfigRequested {
+			// Nothing else matters, any other problems would just be noise after the help.
+			if c.options.ExitOnHelp {
+				c.exit(0)
+			}
 			return err
 		}
+		errs.add(err)
+		c.setCommandPath()
 		if len(c.remaining) > 0 {
-			setting := c.settings.FindRemaining()
+			setting := c.findRemaining()
 			if setting != nil {
 				var err error
 				setting.reflectValue, err = strListToType(setting.reflectType, c.remaining)
 				if err != nil {
-					return fmt.Errorf("failed to parse remaining arguments into cfg.%s: %s", setting.name, err)
+					errs.add(fmt.Errorf("failed to parse remaining arguments into cfg.%s: %s", setting.name, err))
 				}
 				setting.valueSet = true
+				setting.whereSet = Source{Kind: SourceCommandLine, Name: "remaining arguments"}
 			}
 		}
 	}
-	// fmt.Println("After Commandline")
-	// debugf("%+v\n", c.settings)
 
 	// Check if all required settings are set
-	err = c.settings.CheckRequired()
-	if err != nil {
-		if !c.options.DisableHelpOnMissingRequired {
-			c.printHelp()
+	missing := c.settings.CheckRequired()
+	errs.add(missing)
+	// Check the values against the validation tags, now that all the layers are merged
+	errs.add(c.settings.CheckValid())
+	if len(errs.Errors) > 0 {
+		if missing != nil && !c.options.DisableHelpOnMissingRequired && !c.reloading {
+			errs.add(c.printHelp())
 		}
+		return errs
+	}
+	// Now we have parsed all the settings from file and commandline so we can set the values, in
+	// a copy of the cfg struct so that it is left alone if the copy doesn't pass its validators
+	filled := reflect.New(reflect.TypeOf(cfg).Elem())
+	filled.Elem().Set(reflect.ValueOf(cfg).Elem())
+	err = setValues(filled.Interface(), c.settings)
+	if err != nil {
 		return err
 	}
-	// Now we have parsed all the settings from file and commandline
-	// so we can set the values in the cfg struct
-	err = setValues(cfg, c.settings)
-	return err
+	// Now the struct is filled in it can check itself
+	runValidators(filled.Elem(), c.settings, "", errs)
+	if len(errs.Errors) > 0 {
+		return errs
+	}
+	reflect.ValueOf(cfg).Elem().Set(filled.Elem())
+	if c.printConfigRequested && !c.reloading {
+		c.printConfig()
+	}
+	return nil
+}
+
+func (c *cfgApe) exit(code int) {
+	if c.options.exit != nil {
+		c.options.exit(code)
+		return
+	}
+	os.Exit(code)
 }
@@ -4,6 +4,7 @@ package configape
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -134,6 +135,36 @@ func TestRequired(t *testing.T) {
 	}
 }
 
+func TestHelpWithMissingRequired(t *testing.T) {
+	cfg := struct {
+		Foo string `required:"true"`
+	}{}
+	buffer := &strings.Builder{}
+	exitCode := -1
+	options := Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		HelpWriter:         buffer,
+		osArgs:             []string{"cfgape", "--help"},
+	}
+	err := Apply(&cfg, &options)
+	if err != ErrHelpRequested {
+		t.Fatalf("Expected ErrHelpRequested, got: %v", err)
+	}
+	if strings.Count(buffer.String(), "Usage:") != 1 {
+		t.Errorf("Expected the help to be printed once:\n%s", buffer.String())
+	}
+
+	// With ExitOnHelp the program exits cleanly instead
+	options.ExitOnHelp = true
+	options.osArgs = []string{"cfgape", "--version"}
+	options.exit = func(code int) { exitCode = code }
+	Apply(&cfg, &options)
+	if exitCode != 0 {
+		t.Errorf("Expected exit code 0, got %d", exitCode)
+	}
+}
+
 func TestAllSettings(t *testing.T) {
 	cfg := struct {
 		Default string `cfg:"foo" default:"baz"`
@@ -305,3 +336,47 @@ func TestMarshalling(t *testing.T) {
 		t.Error("Foo was not barbaz")
 	}
 }
+
+func TestWhereSetConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	os.WriteFile(first, []byte(`{"foo": "first", "bar": "first"}`), 0644)
+	os.WriteFile(second, []byte(`{"bar": "second"}`), 0644)
+	cfg := struct {
+		Foo string
+		Bar string
+	}{}
+	c := cfgApe{}
+	err := c.Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableCommandLine: true,
+		ConfigFilenames:    []string{first, second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.settings[0].whereSet.Name != first {
+		t.Errorf("foo was set by %s, not %s", c.settings[0].whereSet, first)
+	}
+	if c.settings[1].whereSet.Name != second {
+		t.Errorf("bar was set by %s, not %s", c.settings[1].whereSet, second)
+	}
+}
+
+func TestExpandConfigPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	path, ok := expandConfigPath("$XDG_CONFIG_HOME/app/config.yaml")
+	if !ok || path != filepath.Join(home, ".config/app/config.yaml") {
+		t.Errorf("XDG_CONFIG_HOME did not default to ~/.config: %s", path)
+	}
+	path, ok = expandConfigPath("~/.app.yaml")
+	if !ok || path != filepath.Join(home, ".app.yaml") {
+		t.Errorf("~ was not expanded: %s", path)
+	}
+	_, ok = expandConfigPath("$CONFIGAPE_TEST_UNSET_VARIABLE/config.yaml")
+	if ok {
+		t.Error("path with an unset variable should not be ok")
+	}
+}
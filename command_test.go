@@ -0,0 +1,149 @@
+package configape
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type serveCommand struct {
+	Port int `default:"8080" help:"Port to listen on"`
+}
+
+type migrateCommand struct {
+	Up *struct {
+		Steps int `required:"true"`
+	} `cfgtype:"command" help:"Apply migrations"`
+	Down *struct{} `cfgtype:"command" help:"Revert migrations"`
+}
+
+type commandConfig struct {
+	Verbose bool     `short:"v"`
+	Host    string   `default:"localhost"`
+	Command string   `cfgtype:"commandpath"`
+	Path    []string `cfgtype:"commandpath"`
+
+	Serve   *serveCommand  `cfgtype:"command" help:"Run the server"`
+	Migrate migrateCommand `cfgtype:"command" help:"Manage the database"`
+	UserAdd *struct {
+		Admin bool
+		Names []string `name:"*"`
+	} `cfgtype:"command"`
+}
+
+func TestCommands(t *testing.T) {
+	os.Setenv("CFG_HOST", "db.example.com")
+	defer os.Unsetenv("CFG_HOST")
+
+	cfg := commandConfig{}
+	err := Apply(&cfg, &Options{
+		ConfigFilename:  "test.json",
+		cfgFileContents: `{"verbose": true}`,
+		osArgs:          []string{"cfgape", "serve", "--port", "80"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Serve == nil || cfg.Serve.Port != 80 {
+		t.Errorf("expected serve with port 80, got %+v", cfg.Serve)
+	}
+	if cfg.Command != "serve" || !reflect.DeepEqual(cfg.Path, []string{"serve"}) {
+		t.Errorf("expected the command path serve, got %q, %q", cfg.Command, cfg.Path)
+	}
+	// The config file and environment still fill in the global settings
+	if !cfg.Verbose || cfg.Host != "db.example.com" {
+		t.Errorf("expected the global settings to be set, got %+v", cfg)
+	}
+	if cfg.UserAdd != nil {
+		t.Errorf("expected user-add to not be selected")
+	}
+
+	// Nested commands, with global flags after the command
+	cfg = commandConfig{}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "migrate", "up", "--steps=2", "-v"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Command != "migrate up" || cfg.Migrate.Up == nil || cfg.Migrate.Up.Steps != 2 || cfg.Migrate.Down != nil || !cfg.Verbose {
+		t.Errorf("expected migrate up with 2 steps, got %q %+v", cfg.Command, cfg.Migrate)
+	}
+	if cfg.Serve != nil {
+		t.Errorf("expected serve to not be selected")
+	}
+
+	// A command with remaining arguments
+	cfg = commandConfig{}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "user-add", "--admin", "alice", "bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.UserAdd == nil || !cfg.UserAdd.Admin || !reflect.DeepEqual(cfg.UserAdd.Names, []string{"alice", "bob"}) {
+		t.Errorf("expected user-add for alice and bob, got %+v", cfg.UserAdd)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	cfg := commandConfig{}
+	err := Apply(&cfg, &Options{
+		DisableConfigFile:            true,
+		DisableHelpOnMissingRequired: true,
+		osArgs:                       []string{"cfgape", "serv", "--port=80"},
+	})
+	var unknown *ErrUnknownArgument
+	if !errors.As(err, &unknown) || unknown.Argument != "serv" {
+		t.Errorf("expected an unknown command error, got: %v", err)
+	}
+	// The port flag belongs to the serve command
+	if !strings.HasSuffix(err.Error(), "unknown command line argument: --port") {
+		t.Errorf("expected --port to be unknown without the command, got: %v", err)
+	}
+
+	// Required settings are only required for the selected command
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableHelpOnMissingRequired: true, osArgs: []string{"cfgape", "migrate", "up"}})
+	var required *ErrRequired
+	if !errors.As(err, &required) || required.Setting != "migrate.up.steps" {
+		t.Errorf("expected migrate.up.steps to be required, got: %v", err)
+	}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "migrate", "down"}})
+	if err != nil {
+		t.Errorf("expected no error for migrate down, got: %v", err)
+	}
+
+	// Commands can't be set from the config file
+	err = Apply(&cfg, &Options{ConfigFilename: "test.json", cfgFileContents: `{"serve": {"port": 1}}`, osArgs: []string{"cfgape"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown setting in config file: serve") {
+		t.Errorf("expected serve to be unknown in the config file, got: %v", err)
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	buffer := &strings.Builder{}
+	cfg := commandConfig{}
+	err := Apply(&cfg, &Options{DisableConfigFile: true, Name: "tool", HelpWriter: buffer, osArgs: []string{"cfgape", "--help"}})
+	if err != ErrHelpRequested {
+		t.Fatalf("expected ErrHelpRequested, got: %v", err)
+	}
+	help := buffer.String()
+	for _, expected := range []string{"Usage: tool [options] <command>\n", "Commands:", "  serve                               Run the server\n", "  migrate ", "  user-add\n"} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("expected the help to contain %q:\n%s", expected, help)
+		}
+	}
+	if strings.Contains(help, "--port") || strings.Contains(help, "--command") {
+		t.Errorf("expected the help to not contain command settings:\n%s", help)
+	}
+
+	buffer.Reset()
+	err = Apply(&cfg, &Options{DisableConfigFile: true, Name: "tool", HelpWriter: buffer, osArgs: []string{"cfgape", "migrate", "--help"}})
+	if err != ErrHelpRequested {
+		t.Fatalf("expected ErrHelpRequested, got: %v", err)
+	}
+	help = buffer.String()
+	for _, expected := range []string{"Usage: tool migrate [options] <command>\n", "tool migrate\n  Manage the database\n", "  up                                  Apply migrations\n", "Global options:", "--verbose"} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("expected the migrate help to contain %q:\n%s", expected, help)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package configape
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 )
 
@@ -29,25 +30,24 @@ func (c *cfgApe) getCliConfigFile(osArgs []string) (string, error) {
 			break
 		}
 		// It's an argument
-		// Strip off the --
-		arg = strings.ToLower(arg[2:])
+		// Strip off the --, the value can be after an equals (eg --config=path)
+		name, value, hasValue := strings.Cut(arg[2:], "=")
+		name = strings.ToLower(name)
+		matches := name == configArgName || name == configArgHyphen || name == configArgUnderscore
 		if setting.cliName != "" {
-			if arg == setting.cliName {
-				// Grab the next argument
-				if idx >= len(osArgs) {
-					return "", fmt.Errorf("missing value for argument: %s", arg)
-				}
-				idx++
-				return osArgs[idx], nil
-			}
-		} else if arg == configArgName || arg == configArgHyphen || arg == configArgUnderscore {
-			// Grab the next argument
-			if idx >= len(osArgs) {
-				return "", fmt.Errorf("missing value for argument: %s", arg)
-			}
-			idx++
-			return osArgs[idx], nil
+			matches = name == setting.cliName
+		}
+		if !matches {
+			continue
+		}
+		if hasValue {
+			return value, nil
+		}
+		// Grab the next argument
+		if idx+1 >= len(osArgs) {
+			return "", fmt.Errorf("missing value for argument: %s", arg)
 		}
+		return osArgs[idx+1], nil
 	}
 	return "", nil
 }
@@ -57,19 +57,16 @@ func stringPtr(s string) *string {
 	return &s
 }
 
+// Parse the command line into the settings, all the problems found are returned as a MultiError.
+// If --help, --version, --completion or --generate-config is found then it is printed and
+// ErrHelpRequested, ErrVersionRequested, ErrCompletionRequested or ErrGenerateConfigRequested
+// is returned instead, without parsing the rest of the arguments.
 func (c *cfgApe) parseCommandLine(osArgs []string) error {
-	// Loop while osArgs has something in it
-	max := 50
+	errs := &MultiError{}
 	// Pop the program name off the stack
 	osArgs = osArgs[1:]
+	// Each time round takes at least one argument, so this ends when they run out
 	for len(osArgs) > 0 {
-		// DEBUG
-		max -= 1
-		if max == 0 {
-			debugf("Something is wrong, max iterations exceeded\n")
-			return fmt.Errorf("too many iterations")
-		}
-
 		var arg string
 		arg, osArgs = osArgs[0], osArgs[1:]
 		what := arg
@@ -78,7 +75,6 @@ func (c *cfgApe) parseCommandLine(osArgs []string) error {
 			c.remaining = append(c.remaining, osArgs...)
 			break
 		}
-		//debugf("arg: %s\n", arg)
 
 		if strings.HasPrefix(arg, "--") {
 			// Longform argument
@@ -94,11 +90,31 @@ func (c *cfgApe) parseCommandLine(osArgs []string) error {
 				forceValue = &value
 			}
 			// Find the setting
-			setting := c.settings.FindRecursive(arg, "cli")
+			setting := c.findCliSetting(arg)
+			if setting == nil && strings.HasSuffix(arg, "-file") {
+				// A secret can be read from a file with --secret-name-file
+				secret := c.findCliSetting(strings.TrimSuffix(arg, "-file"))
+				if secret != nil && secret.secret {
+					if forceValue == nil {
+						if len(osArgs) == 0 {
+							errs.add(fmt.Errorf("missing value for argument: %s", what))
+							break
+						}
+						forceValue, osArgs = &osArgs[0], osArgs[1:]
+					}
+					contents, err := readSecretFile(*forceValue)
+					if err != nil {
+						errs.add(fmt.Errorf("failed to read %s into cfg.%s: %s", what, secret.name, err))
+						continue
+					}
+					setting = secret
+					forceValue = &contents
+				}
+			}
 			if setting == nil && strings.HasPrefix(arg, "no-") {
 				// We didn't find an existing field with the name `no-foo`, so let's try `foo`
 				// If it's a no- then strip that off and find the setting
-				setting = c.settings.FindRecursive(arg[3:], "cli")
+				setting = c.findCliSetting(arg[3:])
 				if setting != nil {
 					forceValue = stringPtr("false")
 				}
@@ -106,36 +122,78 @@ func (c *cfgApe) parseCommandLine(osArgs []string) error {
 			if setting == nil {
 				// if they asked for help, then spit it out
 				if arg == "help" && !c.options.DisableHelp {
-					c.printHelp()
-					return nil
+					if err := c.printHelp(); err != nil {
+						return err
+					}
+					return ErrHelpRequested
 				}
 				if arg == "version" && !c.options.DisableVersion {
 					c.printVersion()
-					return nil
+					return ErrVersionRequested
 				}
-				return fmt.Errorf("unknown command line argument: %s", what)
-			}
-			err := c.setSetting(setting, forceValue, what, &osArgs)
-			if err != nil {
-				return err
+				if arg == "completion" && c.options.EnableCompletion {
+					if forceValue == nil {
+						if len(osArgs) == 0 {
+							errs.add(fmt.Errorf("missing value for argument: %s", what))
+							continue
+						}
+						forceValue, osArgs = &osArgs[0], osArgs[1:]
+					}
+					err := c.printCompletion(*forceValue)
+					if err != nil {
+						errs.add(err)
+						continue
+					}
+					return ErrCompletionRequested
+				}
+				if arg == "generate-config" && c.options.EnableGenerateConfig {
+					format := ""
+					if forceValue != nil {
+						format = *forceValue
+					}
+					err := c.printConfigFile(format)
+					if err != nil {
+						errs.add(err)
+						continue
+					}
+					return ErrGenerateConfigRequested
+				}
+				if arg == "print-config" && c.options.EnablePrintConfig {
+					c.printConfigRequested = true
+					continue
+				}
+				// Without the value, which could be a secret given to a misspelt argument
+				errs.add(&ErrUnknownArgument{Argument: "--" + arg})
+				continue
 			}
+			errs.add(c.setSetting(setting, forceValue, what, &osArgs))
 		} else if strings.HasPrefix(arg, "-") {
 			// Shortform argument
 			args := arg[1:]
 			for _, a := range args {
 				arg := string(a)
-				setting := c.settings.FindShort(arg)
-				err := c.setSetting(setting, nil, what, &osArgs)
-				if err != nil {
-					return err
-				}
+				errs.add(c.setSetting(c.findShortSetting(arg), nil, what, &osArgs))
 			}
 		} else {
+			if len(c.remaining) == 0 {
+				// Words before any remaining arguments can select a command
+				scope := c.scopes()[0]
+				if command := scope.FindCommand(arg); command != nil {
+					command.valueSet = true
+					command.whereSet = Source{Kind: SourceCommandLine, Name: arg}
+					c.commands = append(c.commands, command)
+					continue
+				}
+				if scope.hasCommands() && scope.FindRemaining() == nil {
+					errs.add(&ErrUnknownArgument{Argument: what})
+					continue
+				}
+			}
 			c.remaining = append(c.remaining, arg)
 			continue
 		}
 	}
-	return nil
+	return errs.errorOrNil()
 }
 
 func (c *cfgApe) setSetting(setting *cfgSetting, forceValue *string, whereFrom string, args *[]string) error {
@@ -143,10 +201,9 @@ func (c *cfgApe) setSetting(setting *cfgSetting, forceValue *string, whereFrom s
 	var err error
 
 	if setting == nil {
-		return fmt.Errorf("unknown command line argument: %s", whereFrom)
+		return &ErrUnknownArgument{Argument: whereFrom}
 	}
 	var value string
-	setting.whereSet = whereFrom
 	//debugf("Setting %s, forceValue: %v, whereFrom: %s, args: %v\n", setting.name, forceValue, whereFrom, *args)
 
 	// If it's a boolean, then set it to true
@@ -157,6 +214,7 @@ func (c *cfgApe) setSetting(setting *cfgSetting, forceValue *string, whereFrom s
 			value = "true"
 		}
 	} else if setting.fieldType == fieldTypeCounter {
+		setting.whereSet = Source{Kind: SourceCommandLine, Name: whereFrom}
 		setting.reflectValue, err = incrementNumber(setting.reflectType, setting.reflectValue, 1)
 		if err != nil {
 			return fmt.Errorf("failed to parse %s into cfg.%s: %s", whereFrom, setting.name, err)
@@ -175,15 +233,100 @@ func (c *cfgApe) setSetting(setting *cfgSetting, forceValue *string, whereFrom s
 		}
 	}
 
+	// Just the flag, as the argument can contain the value (eg --password=secret), which is the
+	// Input of any error
+	if idx := strings.Index(whereFrom, "="); idx != -1 {
+		whereFrom = whereFrom[:idx]
+	}
+	setting.whereSet = Source{Kind: SourceCommandLine, Name: whereFrom}
+
 	if setting.fieldType == fieldTypeList {
 		//setting.values = append(setting.values, value)
 		setting.reflectValue, err = appendStrToListType(setting.reflectType, setting.reflectValue, value)
+	} else if setting.fieldType == fieldTypeMap {
+		// Each --label key=value adds to the map
+		setting.reflectValue, err = addStrToMapType(setting.reflectType, setting.reflectValue, value)
 	} else {
-		setting.reflectValue, err = strToType(setting.reflectType, value)
+		setting.reflectValue, err = setting.parseValue(value)
 	}
 	if err != nil {
-		return fmt.Errorf("failed to parse %s=%s into cfg.%s: %s", whereFrom, value, setting.name, err)
+		return &ErrParseValue{
+			Setting: setting.path,
+			Name:    setting.name,
+			Source:  setting.whereSet,
+			Input:   setting.redact(value),
+			Err:     setting.redactError(err, value),
+		}
 	}
 	setting.valueSet = true
 	return nil
 }
+
+// Returns the settings that can be used on the command line, the innermost selected command
+// first and the global settings last.
+func (c *cfgApe) scopes() []cfgSettings {
+	scopes := []cfgSettings{}
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		scopes = append(scopes, c.commands[i].subsection)
+	}
+	return append(scopes, c.settings)
+}
+
+func (c *cfgApe) findCliSetting(name string) *cfgSetting {
+	for _, scope := range c.scopes() {
+		if setting := scope.FindRecursive(name, "cli"); setting != nil {
+			return setting
+		}
+	}
+	return nil
+}
+
+func (c *cfgApe) findShortSetting(name string) *cfgSetting {
+	for _, scope := range c.scopes() {
+		if setting := scope.FindShort(name); setting != nil {
+			return setting
+		}
+	}
+	return nil
+}
+
+func (c *cfgApe) findRemaining() *cfgSetting {
+	for _, scope := range c.scopes() {
+		if setting := scope.FindRemaining(); setting != nil {
+			return setting
+		}
+	}
+	return nil
+}
+
+// The words of the selected commands, eg ["migrate", "up"]
+func (c *cfgApe) commandPath() []string {
+	path := []string{}
+	for _, command := range c.commands {
+		path = append(path, command.commandName())
+	}
+	return path
+}
+
+// Sets the commandpath settings to the selected commands.
+func (c *cfgApe) setCommandPath() {
+	if len(c.commands) == 0 {
+		return
+	}
+	path := c.commandPath()
+	for _, scope := range c.scopes() {
+		for i := 0; i < len(scope); i++ {
+			setting := &scope[i]
+			if setting.fieldType != fieldTypeCommandPath {
+				continue
+			}
+			if setting.reflectType.Kind() == reflect.Slice {
+				setting.reflectValue = reflect.ValueOf(path).Convert(setting.reflectType)
+			} else {
+				setting.reflectValue = reflect.ValueOf(strings.Join(path, " ")).Convert(setting.reflectType)
+			}
+			setting.valueSet = true
+			setting.whereSet = Source{Kind: SourceCommandLine, Name: strings.Join(path, " ")}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package configape
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -29,6 +30,23 @@ func TestParseCommandline(t *testing.T) {
 	}
 }
 
+func TestManyArguments(t *testing.T) {
+	args := []string{"test"}
+	for i := 0; i < 100; i++ {
+		args = append(args, "--name", fmt.Sprintf("name%d", i))
+	}
+	cfg := struct {
+		Name []string
+	}{}
+	err := Apply(&cfg, &Options{DisableEnviornment: true, DisableConfigFile: true, osArgs: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Name) != 100 || cfg.Name[99] != "name99" {
+		t.Errorf("expected all 100 names, got %v", cfg.Name)
+	}
+}
+
 func TestShortArguments(t *testing.T) {
 	args := []string{"test", "--foo", "bar", "-b", "thing"}
 	cfg := struct {
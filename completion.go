@@ -0,0 +1,397 @@
+package configape
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// A command line argument that can be completed.
+type completionFlag struct {
+	name   string   // The argument including the dashes, eg --database-host or -v
+	help   string   // The first line of the help
+	value  bool     // Does it take a value
+	values []string // The values it can take, from the oneof tag
+	file   bool     // Is the value a file
+}
+
+// The arguments that can be completed for a command, the global settings have an empty path.
+type completionScope struct {
+	path     string // The command words, eg /migrate/up
+	name     string // The command word, eg up
+	help     string
+	flags    []completionFlag // Including those of the enclosing commands
+	commands []completionScope
+}
+
+// GenerateCompletion returns a completion script for the shell (bash, zsh or fish), that completes
+// the command line arguments of cfg. Options.Name is the name of the program being completed.
+func GenerateCompletion(cfg interface{}, shell string, options *Options) (string, error) {
+	c := cfgApe{}
+	c.cfg = cfg
+	if options == nil {
+		options = &Options{}
+	}
+	c.options = *options
+
+	err := c.parseStructIntoSettings()
+	if err != nil {
+		return "", err
+	}
+	return c.makeCompletion(shell)
+}
+
+func (c *cfgApe) makeCompletion(shell string) (string, error) {
+	name := c.options.Name
+	if name == "" {
+		name = os.Args[0]
+	}
+	// Only the program name is completed, not the path to it
+	if idx := strings.LastIndex(name, "/"); idx != -1 {
+		name = name[idx+1:]
+	}
+	root := completionScope{flags: c.builtinCompletionFlags()}
+	root = makeCompletionScope(root, c.helpSettings(c.settings, "", "", "", false), c.helpCommands(c.settings, ""))
+
+	switch shell {
+	case "bash":
+		return bashCompletion(name, root), nil
+	case "zsh":
+		return zshCompletion(name, root), nil
+	case "fish":
+		return fishCompletion(name, root), nil
+	}
+	return "", fmt.Errorf("unknown shell for completion: %s (use bash, zsh or fish)", shell)
+}
+
+// Prints the completion script for --completion
+func (c *cfgApe) printCompletion(shell string) error {
+	script, err := c.makeCompletion(shell)
+	if err != nil {
+		return err
+	}
+	// Completion scripts are normally sourced, so go to stdout rather than stderr like the help
+	fh := c.options.HelpWriter
+	if fh == nil {
+		fh = os.Stdout
+	}
+	fmt.Fprint(fh, script)
+	return nil
+}
+
+func (c *cfgApe) builtinCompletionFlags() []completionFlag {
+	var flags []completionFlag
+	if !c.options.DisableHelp {
+		flags = append(flags, completionFlag{name: "--help", help: "Show the help"})
+	}
+	if !c.options.DisableVersion {
+		flags = append(flags, completionFlag{name: "--version", help: "Show the version"})
+	}
+	if c.options.EnablePrintConfig {
+		flags = append(flags, completionFlag{name: "--print-config", help: "Show the configuration and where it came from"})
+	}
+	if c.options.EnableCompletion {
+		flags = append(flags, completionFlag{name: "--completion", help: "Print the shell completion script", value: true, values: []string{"bash", "zsh", "fish"}})
+	}
+	if c.options.EnableGenerateConfig {
+		flags = append(flags, completionFlag{name: "--generate-config", help: "Print a sample config file"})
+	}
+	return flags
+}
+
+// Adds the arguments and commands to the scope, which already has the flags of the enclosing
+// commands.
+func makeCompletionScope(scope completionScope, settings []*HelpSetting, commands []*HelpCommand) completionScope {
+	scope.flags = append(scope.flags, completionFlags(settings)...)
+	for _, command := range commands {
+		sub := completionScope{
+			path:  scope.path + "/" + command.Name,
+			name:  command.Name,
+			help:  firstLine(command.Help),
+			flags: append([]completionFlag{}, scope.flags...),
+		}
+		scope.commands = append(scope.commands, makeCompletionScope(sub, command.Settings, command.Commands))
+	}
+	return scope
+}
+
+// The arguments for the settings that can be used on the command line, as shown in the help.
+func completionFlags(settings []*HelpSetting) []completionFlag {
+	var flags []completionFlag
+	for _, setting := range flattenHelpSettings(settings) {
+		if setting.Flag == "" {
+			continue
+		}
+		flag := completionFlag{
+			name:   setting.Flag,
+			help:   firstLine(setting.Help),
+			value:  setting.Value != "",
+			values: setting.setting.validation.oneOf,
+			file:   setting.setting.fieldType == fieldTypeConfigFile,
+		}
+		flags = append(flags, flag)
+		if setting.Short != "" {
+			short := flag
+			short.name = setting.Short
+			flags = append(flags, short)
+		}
+		if setting.setting.fieldType == fieldTypeFlag {
+			// The no- goes after the subsection prefix, eg --database-no-verbose
+			name := setting.setting.cliFlagName()
+			flags = append(flags, completionFlag{name: strings.TrimSuffix(setting.Flag, name) + "no-" + name})
+		}
+		if setting.Secret && flag.value {
+			flags = append(flags, completionFlag{name: flag.name + "-file", help: flag.help, value: true, file: true})
+		}
+	}
+	return flags
+}
+
+func firstLine(str string) string {
+	return strings.TrimSpace(strings.SplitN(str, "\n", 2)[0])
+}
+
+// Calls fn for the scope and all of its commands
+func (s completionScope) walk(fn func(scope completionScope)) {
+	fn(s)
+	for _, command := range s.commands {
+		command.walk(fn)
+	}
+}
+
+// The paths of all the commands, eg /serve, /migrate, /migrate/up
+func (s completionScope) commandPaths() []string {
+	var paths []string
+	s.walk(func(scope completionScope) {
+		if scope.path != "" {
+			paths = append(paths, scope.path)
+		}
+	})
+	return paths
+}
+
+// The arguments that take a value, each name only once.
+func (s completionScope) valueFlags() []completionFlag {
+	var flags []completionFlag
+	seen := map[string]bool{}
+	s.walk(func(scope completionScope) {
+		for _, flag := range scope.flags {
+			if flag.value && !seen[flag.name] {
+				seen[flag.name] = true
+				flags = append(flags, flag)
+			}
+		}
+	})
+	return flags
+}
+
+// Arguments that take a value that isn't from a list or a file, so nothing is completed.
+func plainValueFlags(flags []completionFlag) []string {
+	var names []string
+	for _, flag := range flags {
+		if len(flag.values) == 0 && !flag.file {
+			names = append(names, flag.name)
+		}
+	}
+	return names
+}
+
+// The shell function name for the program, eg my-prog is _my_prog
+var nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func completionFunction(name string) string {
+	return "_" + nonIdentifierChars.ReplaceAllString(name, "_")
+}
+
+// Quotes for the inside of double quotes in bash and zsh
+var doubleQuoter = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+func doubleQuote(str string) string {
+	return `"` + doubleQuoter.Replace(str) + `"`
+}
+
+func singleQuote(str string) string {
+	return "'" + strings.ReplaceAll(str, "'", `'\''`) + "'"
+}
+
+func bashCompletion(name string, root completionScope) string {
+	fn := completionFunction(name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n", name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cur=\"${COMP_WORDS[COMP_CWORD]}\" prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	// --flag=value is split into --flag, = and value
+	b.WriteString("    if [[ \"$prev\" == \"=\" ]]; then\n")
+	b.WriteString("        prev=\"${COMP_WORDS[COMP_CWORD-2]}\"\n")
+	b.WriteString("    elif [[ \"$cur\" == \"=\" ]]; then\n")
+	b.WriteString("        cur=\"\"\n")
+	b.WriteString("    fi\n")
+	b.WriteString("    local cmdpath=\"\" word i\n")
+	if paths := root.commandPaths(); len(paths) > 0 {
+		b.WriteString("    for ((i = 1; i < COMP_CWORD; i++)); do\n")
+		b.WriteString("        word=\"${COMP_WORDS[i]}\"\n")
+		b.WriteString("        case \"$cmdpath/$word\" in\n")
+		fmt.Fprintf(&b, "        %s) cmdpath=\"$cmdpath/$word\" ;;\n", strings.Join(paths, "|"))
+		b.WriteString("        esac\n")
+		b.WriteString("    done\n")
+	}
+	valueFlags := root.valueFlags()
+	if len(valueFlags) > 0 {
+		b.WriteString("    case \"$prev\" in\n")
+		for _, flag := range valueFlags {
+			if len(flag.values) > 0 {
+				fmt.Fprintf(&b, "    %s) COMPREPLY=($(compgen -W %s -- \"$cur\")); return ;;\n", flag.name, doubleQuote(strings.Join(flag.values, " ")))
+			} else if flag.file {
+				fmt.Fprintf(&b, "    %s) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", flag.name)
+			}
+		}
+		if plain := plainValueFlags(valueFlags); len(plain) > 0 {
+			fmt.Fprintf(&b, "    %s) return ;;\n", strings.Join(plain, "|"))
+		}
+		b.WriteString("    esac\n")
+	}
+	b.WriteString("    case \"$cmdpath\" in\n")
+	root.walk(func(scope completionScope) {
+		var words []string
+		for _, flag := range scope.flags {
+			words = append(words, flag.name)
+		}
+		for _, command := range scope.commands {
+			words = append(words, command.name)
+		}
+		fmt.Fprintf(&b, "    %s) COMPREPLY=($(compgen -W %s -- \"$cur\")) ;;\n", doubleQuote(scope.path), doubleQuote(strings.Join(words, " ")))
+	})
+	b.WriteString("    esac\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(&b, "complete -F %s %s\n", fn, name)
+	return b.String()
+}
+
+func zshCompletion(name string, root completionScope) string {
+	fn := completionFunction(name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", name)
+	fmt.Fprintf(&b, "%s() {\n", fn)
+	b.WriteString("    local cmdpath=\"\" word i flag=\"${words[CURRENT-1]}\"\n")
+	if paths := root.commandPaths(); len(paths) > 0 {
+		b.WriteString("    for ((i = 2; i < CURRENT; i++)); do\n")
+		b.WriteString("        word=\"${words[i]}\"\n")
+		b.WriteString("        case \"$cmdpath/$word\" in\n")
+		fmt.Fprintf(&b, "        (%s) cmdpath=\"$cmdpath/$word\" ;;\n", strings.Join(paths, "|"))
+		b.WriteString("        esac\n")
+		b.WriteString("    done\n")
+	}
+	valueFlags := root.valueFlags()
+	if len(valueFlags) > 0 {
+		b.WriteString("    if [[ \"$PREFIX\" == --*=* ]]; then\n")
+		b.WriteString("        flag=\"${PREFIX%%=*}\"\n")
+		b.WriteString("        compset -P '*='\n")
+		b.WriteString("    fi\n")
+		b.WriteString("    case \"$flag\" in\n")
+		for _, flag := range valueFlags {
+			if len(flag.values) > 0 {
+				var quoted []string
+				for _, value := range flag.values {
+					quoted = append(quoted, singleQuote(value))
+				}
+				fmt.Fprintf(&b, "    (%s) compadd -- %s; return ;;\n", flag.name, strings.Join(quoted, " "))
+			} else if flag.file {
+				fmt.Fprintf(&b, "    (%s) _files; return ;;\n", flag.name)
+			}
+		}
+		if plain := plainValueFlags(valueFlags); len(plain) > 0 {
+			fmt.Fprintf(&b, "    (%s) return ;;\n", strings.Join(plain, "|"))
+		}
+		b.WriteString("    esac\n")
+	}
+	b.WriteString("    local -a opts\n")
+	b.WriteString("    case \"$cmdpath\" in\n")
+	root.walk(func(scope completionScope) {
+		fmt.Fprintf(&b, "    (%s) opts=(\n", doubleQuote(scope.path))
+		for _, flag := range scope.flags {
+			fmt.Fprintf(&b, "        %s\n", singleQuote(zshDescription(flag.name, flag.help)))
+		}
+		for _, command := range scope.commands {
+			fmt.Fprintf(&b, "        %s\n", singleQuote(zshDescription(command.name, command.help)))
+		}
+		b.WriteString("    ) ;;\n")
+	})
+	b.WriteString("    esac\n")
+	b.WriteString("    _describe -t options 'option' opts\n")
+	b.WriteString("}\n\n")
+	// Works both from a file in $fpath and with source <(prog --completion zsh)
+	fmt.Fprintf(&b, "if [ \"$funcstack[1]\" = \"%s\" ]; then\n", fn)
+	fmt.Fprintf(&b, "    %s \"$@\"\n", fn)
+	b.WriteString("else\n")
+	fmt.Fprintf(&b, "    compdef %s %s\n", fn, name)
+	b.WriteString("fi\n")
+	return b.String()
+}
+
+// An entry for _describe, the name and description are separated by a colon.
+func zshDescription(name, help string) string {
+	name = strings.ReplaceAll(name, ":", `\:`)
+	if help == "" {
+		return name
+	}
+	return name + ":" + help
+}
+
+// Quotes for fish, which only escapes \ and ' inside single quotes
+func fishQuote(str string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(str) + "'"
+}
+
+func fishCompletion(name string, root completionScope) string {
+	fn := "_" + completionFunction(name) + "_command"
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n", name)
+	// Tests if the command path, eg /migrate/up, is the one selected on the command line.
+	fmt.Fprintf(&b, "function %s -a expected\n", fn)
+	b.WriteString("    set -l cmdpath \"\"\n")
+	if paths := root.commandPaths(); len(paths) > 0 {
+		b.WriteString("    set -l cmdwords (commandline -opc)\n")
+		b.WriteString("    set -e cmdwords[1]\n")
+		b.WriteString("    for word in $cmdwords\n")
+		b.WriteString("        switch \"$cmdpath/$word\"\n")
+		fmt.Fprintf(&b, "            case %s\n", strings.Join(paths, " "))
+		b.WriteString("                set cmdpath \"$cmdpath/$word\"\n")
+		b.WriteString("        end\n")
+		b.WriteString("    end\n")
+	}
+	b.WriteString("    test \"$cmdpath\" = \"$expected\"\n")
+	b.WriteString("end\n\n")
+	// Only complete files for arguments that are files
+	fmt.Fprintf(&b, "complete -c %s -f\n", name)
+	root.walk(func(scope completionScope) {
+		condition := fishQuote(fmt.Sprintf("%s %s", fn, fishQuote(scope.path)))
+		for _, flag := range scope.flags {
+			line := fmt.Sprintf("complete -c %s -n %s", name, condition)
+			if strings.HasPrefix(flag.name, "--") {
+				line += " -l " + flag.name[2:]
+			} else {
+				line += " -s " + flag.name[1:]
+			}
+			if len(flag.values) > 0 {
+				line += " -x -a " + fishQuote(strings.Join(flag.values, " "))
+			} else if flag.file {
+				line += " -r -F"
+			} else if flag.value {
+				line += " -x"
+			}
+			if flag.help != "" {
+				line += " -d " + fishQuote(flag.help)
+			}
+			b.WriteString(line + "\n")
+		}
+		for _, command := range scope.commands {
+			line := fmt.Sprintf("complete -c %s -n %s -a %s", name, condition, command.name)
+			if command.help != "" {
+				line += " -d " + fishQuote(command.help)
+			}
+			b.WriteString(line + "\n")
+		}
+	})
+	return b.String()
+}
@@ -0,0 +1,89 @@
+package configape
+
+import (
+	"strings"
+	"testing"
+)
+
+type completionConfig struct {
+	Verbose  bool   `short:"v" help:"Be verbose"`
+	Level    string `oneof:"debug,info" help:"Log level"`
+	Config   string `cfgtype:"configfile"`
+	Password string `secret:"true"`
+	Database struct {
+		Host string `help:"Database host"`
+	}
+	Serve *struct {
+		Port int
+	} `cfgtype:"command" help:"Run the server"`
+}
+
+func TestGenerateCompletion(t *testing.T) {
+	options := &Options{Name: "/usr/bin/tool", EnableCompletion: true}
+	tests := map[string][]string{
+		"bash": {
+			"complete -F _tool tool\n",
+			`--level) COMPREPLY=($(compgen -W "debug info" -- "$cur")); return ;;`,
+			`--config) compopt -o filenames 2>/dev/null; COMPREPLY=($(compgen -f -- "$cur")); return ;;`,
+			`--password-file) compopt`,
+			`--completion) COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;`,
+			`"") COMPREPLY=($(compgen -W "--help --version --completion --verbose -v --no-verbose --level --config --password --password-file --database-host serve" -- "$cur")) ;;`,
+			`"/serve") COMPREPLY=($(compgen -W "--help --version --completion --verbose -v --no-verbose --level --config --password --password-file --database-host --port" -- "$cur")) ;;`,
+		},
+		"zsh": {
+			"#compdef tool\n",
+			"(--level) compadd -- 'debug' 'info'; return ;;",
+			"(--config) _files; return ;;",
+			"'--database-host:Database host'",
+			"'serve:Run the server'",
+			"compdef _tool tool",
+		},
+		"fish": {
+			"complete -c tool -n '__tool_command \\'\\'' -s v -d 'Be verbose'\n",
+			"complete -c tool -n '__tool_command \\'\\'' -l level -x -a 'debug info' -d 'Log level'\n",
+			"complete -c tool -n '__tool_command \\'\\'' -l config -r -F\n",
+			"complete -c tool -n '__tool_command \\'\\'' -a serve -d 'Run the server'\n",
+			"complete -c tool -n '__tool_command \\'/serve\\'' -l port -x\n",
+		},
+	}
+	for shell, expected := range tests {
+		script, err := GenerateCompletion(&completionConfig{}, shell, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, str := range expected {
+			if !strings.Contains(script, str) {
+				t.Errorf("%s: expected the script to contain %q:\n%s", shell, str, script)
+			}
+		}
+	}
+
+	_, err := GenerateCompletion(&completionConfig{}, "powershell", options)
+	if err == nil || !strings.Contains(err.Error(), "unknown shell") {
+		t.Errorf("expected an unknown shell error, got %v", err)
+	}
+}
+
+func TestCompletionFlag(t *testing.T) {
+	buffer := &strings.Builder{}
+	cfg := completionConfig{}
+	err := Apply(&cfg, &Options{
+		Name:              "tool",
+		EnableCompletion:  true,
+		DisableConfigFile: true,
+		HelpWriter:        buffer,
+		osArgs:            []string{"tool", "--completion", "bash"},
+	})
+	if err != ErrCompletionRequested {
+		t.Fatalf("expected ErrCompletionRequested, got: %v", err)
+	}
+	if !strings.HasPrefix(buffer.String(), "# bash completion for tool\n") {
+		t.Errorf("expected the bash completion script, got:\n%s", buffer.String())
+	}
+
+	// It's an unknown argument unless enabled
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"tool", "--completion=bash"}})
+	if err == nil || err.Error() != "unknown command line argument: --completion" {
+		t.Errorf("expected an unknown argument error, got: %v", err)
+	}
+}
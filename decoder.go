@@ -0,0 +1,270 @@
+package configape
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A ConfigNode is a single key in a decoded config file. The root node returned by
+// a ConfigDecoder has no key, and its Children are the top level keys of the file.
+type ConfigNode struct {
+	Key      string        // The key as written in the config file, empty for the root node
+	Value    interface{}   // The decoded value: nil, bool, string, int64, float64, []interface{} or map[string]interface{}
+	Children []*ConfigNode // The keys of an object/table/mapping in file order, nil if the node isn't an object
+	Line     int           // The line the key was found on, zero if unknown
+	Column   int           // The column the key was found at, zero if unknown
+
+	// Optional, decodes the node directly into v (a pointer). This lets a format use its
+	// own unmarshalers (eg json.Unmarshaler). If not set, Value is converted via json.
+	Decode func(v interface{}) error
+}
+
+// Returns true if the node is an object (and so could be a subsection).
+func (n *ConfigNode) IsObject() bool {
+	return n.Children != nil
+}
+
+// A ConfigDecoder turns the contents of a config file into a tree of ConfigNodes.
+type ConfigDecoder interface {
+	Decode(r io.Reader) (*ConfigNode, error)
+}
+
+// ConfigDecoderFunc lets an ordinary function be used as a ConfigDecoder.
+type ConfigDecoderFunc func(r io.Reader) (*ConfigNode, error)
+
+func (f ConfigDecoderFunc) Decode(r io.Reader) (*ConfigNode, error) {
+	return f(r)
+}
+
+var (
+	decodersMutex sync.RWMutex
+	decoders      = map[string]ConfigDecoder{
+		"json": ConfigDecoderFunc(decodeJson),
+		"yaml": ConfigDecoderFunc(decodeYaml),
+		"yml":  ConfigDecoderFunc(decodeYaml),
+		"toml": ConfigDecoderFunc(decodeToml),
+	}
+)
+
+// RegisterFormat makes a config file format available to every call to Apply. The ext is the
+// file extension (without the dot) that the format is used for, and replaces any existing format
+// with the same extension. To add a format for a single call use Options.Decoders instead.
+func RegisterFormat(ext string, decoder ConfigDecoder) {
+	decodersMutex.Lock()
+	defer decodersMutex.Unlock()
+	decoders[strings.ToLower(ext)] = decoder
+}
+
+// Find the decoder for the file type, the options take precedence over the registered formats.
+func (c *cfgApe) findDecoder(fileType string) ConfigDecoder {
+	fileType = strings.ToLower(fileType)
+	if decoder, ok := c.options.Decoders[fileType]; ok {
+		return decoder
+	}
+	decodersMutex.RLock()
+	defer decodersMutex.RUnlock()
+	return decoders[fileType]
+}
+
+// Decode the config file with the decoder and apply the result to the settings. All the problems
+// with the file are returned as a MultiError.
+func (c *cfgApe) decodeConfigFile(cfgFile string, decoder ConfigDecoder, fh io.Reader) error {
+	root, err := decoder.Decode(fh)
+	if err != nil {
+		syntaxErr := ErrConfigSyntax{Err: err}
+		var decoderErr *ErrConfigSyntax
+		if errors.As(err, &decoderErr) {
+			syntaxErr = *decoderErr
+		}
+		syntaxErr.File = cfgFile
+		return &syntaxErr
+	}
+	errs := &MultiError{}
+	c.applyConfigNode(c.settings, root, cfgFile, "", errs)
+	return errs.errorOrNil()
+}
+
+// Match each of the children of the node to a setting, recursing into subsections.
+// The prefix is the path of the node, eg "database.".
+func (c *cfgApe) applyConfigNode(settings cfgSettings, node *ConfigNode, cfgFile string, prefix string, errs *MultiError) {
+	for _, child := range node.Children {
+		source := Source{Kind: SourceFile, Name: cfgFile, Line: child.Line, Column: child.Column}
+		setting := settings.Find(child.Key, "config")
+		if setting == nil {
+			if c.options.AllowUnknownConfigFileKeys {
+				continue
+			}
+			errs.add(&ErrUnknownConfigKey{Key: prefix + child.Key, Source: source})
+			continue
+		}
+		// If we've decided it's a subsection, recurse into it.
+		if setting.fieldType == fieldTypeSubsection {
+			if !child.IsObject() {
+				errs.add(&ErrParseValue{
+					Setting: setting.path,
+					Name:    setting.name,
+					Source:  source,
+					Input:   fmt.Sprint(child.Value),
+					Err:     fmt.Errorf("expected an object for %s", child.Key),
+				})
+				continue
+			}
+			c.applyConfigNode(setting.subsection, child, cfgFile, prefix+child.Key+".", errs)
+			continue
+		}
+		value, err := decodeSettingValue(child, setting)
+		if err != nil {
+			input := fmt.Sprint(child.Value)
+			errs.add(&ErrParseValue{
+				Setting: setting.path,
+				Name:    setting.name,
+				Source:  source,
+				Input:   setting.redact(input),
+				Err:     setting.redactError(err, input),
+			})
+			continue
+		}
+		setting.reflectValue = value
+		setting.valueSet = true
+		setting.whereSet = source
+	}
+}
+
+// Decodes the node into a new value of the setting's type. Times with a layout are parsed with it,
+// rather than the format's own idea of a time.
+func decodeSettingValue(node *ConfigNode, setting *cfgSetting) (reflect.Value, error) {
+	if setting.layout != "" {
+		switch value := node.Value.(type) {
+		case string:
+			return setting.parseValue(value)
+		case time.Time:
+			// yaml has already decoded what looked like a timestamp
+			return timeToType(setting.reflectType, value), nil
+		}
+	}
+	// The types parsed from text (eg url.URL) are parsed the same as on the command line
+	if isParsedType(setting.reflectType) {
+		if str, ok := node.Value.(string); ok {
+			return setting.parseValue(str)
+		}
+	} else if setting.fieldType == fieldTypeList && isParsedType(setting.reflectType.Elem()) {
+		if items, ok := node.Value.([]interface{}); ok {
+			list := make([]string, len(items))
+			for idx, item := range items {
+				str, ok := item.(string)
+				if !ok {
+					return reflect.Value{}, fmt.Errorf("expected a string, got %v", item)
+				}
+				list[idx] = str
+			}
+			return strListToType(setting.reflectType, list)
+		}
+	}
+	// Integers are checked so that numbers that aren't whole, or don't fit, are errors that say why
+	if setting.fieldType != fieldTypeCustomMarshaler && isIntegerType(setting.reflectType) {
+		switch value := node.Value.(type) {
+		case int64:
+			return setting.parseValue(strconv.FormatInt(value, 10))
+		case float64:
+			if value != math.Trunc(value) {
+				return reflect.Value{}, fmt.Errorf("invalid integer value: %v", value)
+			}
+		}
+	}
+	return decodeNodeValue(node, setting.reflectType)
+}
+
+// Decodes the node into a new value of valType
+func decodeNodeValue(node *ConfigNode, valType reflect.Type) (reflect.Value, error) {
+	v := reflect.New(valType)
+	var err error
+	// Durations are converted from the value, as not every format can unmarshal them
+	if node.Decode != nil && !hasDuration(valType) {
+		err = node.Decode(v.Interface())
+	} else {
+		// The simplest way to get a plain value into an arbitrary type is to go
+		// via json, which also lets custom json unmarshalers work.
+		var raw []byte
+		var value interface{}
+		value, err = durationsToNumbers(node.Value, valType)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		raw, err = json.Marshal(value)
+		if err == nil {
+			err = json.Unmarshal(raw, v.Interface())
+		}
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return v.Elem(), nil
+}
+
+// Is the type a duration, or a list or map of them
+func hasDuration(valType reflect.Type) bool {
+	for valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	switch valType.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasDuration(valType.Elem())
+	}
+	return valType == durationType
+}
+
+// Durations are strings in config files, eg "30s", but json unmarshals them from a number of
+// nanoseconds. This converts the strings that are for durations into numbers, including those in
+// lists and maps, and rejects numbers.
+func durationsToNumbers(value interface{}, valType reflect.Type) (interface{}, error) {
+	for valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	// A number would be nanoseconds, which is never what was meant by eg timeout: 30
+	if _, ok := value.(string); valType == durationType && !ok && value != nil {
+		return nil, fmt.Errorf("expected a duration with a unit, eg \"30s\", got %v", value)
+	}
+	switch v := value.(type) {
+	case string:
+		if valType == durationType {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid duration value: %s", v)
+			}
+			return int64(d), nil
+		}
+	case []interface{}:
+		if valType.Kind() == reflect.Slice || valType.Kind() == reflect.Array {
+			result := make([]interface{}, len(v))
+			for idx, item := range v {
+				var err error
+				result[idx], err = durationsToNumbers(item, valType.Elem())
+				if err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}
+	case map[string]interface{}:
+		if valType.Kind() == reflect.Map {
+			result := make(map[string]interface{}, len(v))
+			for key, item := range v {
+				var err error
+				result[key], err = durationsToNumbers(item, valType.Elem())
+				if err != nil {
+					return nil, err
+				}
+			}
+			return result, nil
+		}
+	}
+	return value, nil
+}
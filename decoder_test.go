@@ -0,0 +1,120 @@
+package configape
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+)
+
+// A trivial "key=value" format, with dotted keys for subsections.
+func decodeKeyValue(r io.Reader) (*ConfigNode, error) {
+	root := &ConfigNode{Children: []*ConfigNode{}}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		node := root
+		keys := strings.Split(parts[0], ".")
+		for _, key := range keys[:len(keys)-1] {
+			child := &ConfigNode{Key: key, Children: []*ConfigNode{}, Line: line, Column: 1}
+			node.Children = append(node.Children, child)
+			node = child
+		}
+		node.Children = append(node.Children, &ConfigNode{Key: keys[len(keys)-1], Value: parts[1], Line: line, Column: 1})
+	}
+	return root, scanner.Err()
+}
+
+func TestCustomDecoder(t *testing.T) {
+	cfg := struct {
+		Foo     string
+		Section struct {
+			Bar string
+		}
+	}{}
+	options := Options{
+		DisableEnviornment: true,
+		DisableCommandLine: true,
+		ConfigFilename:     "test.kv",
+		cfgFileContents:    "foo=bar\nsection.bar=baz\n",
+		Decoders:           map[string]ConfigDecoder{"kv": ConfigDecoderFunc(decodeKeyValue)},
+	}
+	err := Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "bar" {
+		t.Error("foo was not bar")
+	}
+	if cfg.Section.Bar != "baz" {
+		t.Error("section.bar was not baz")
+	}
+
+	options.cfgFileContents = "foo=bar\nwibble=baz\n"
+	err = Apply(&cfg, &options)
+	if err == nil {
+		t.Error("expected error")
+	} else if !strings.Contains(err.Error(), "wibble at line 2, column 1") {
+		t.Errorf("expected error to contain position of wibble: %s", err)
+	}
+
+	// Without the decoder the format is unknown, until it is registered
+	options.Decoders = nil
+	options.cfgFileContents = "foo=registered\n"
+	err = Apply(&cfg, &options)
+	if err == nil || !strings.Contains(err.Error(), "unknown config file type: kv") {
+		t.Errorf("expected unknown config file type error: %v", err)
+	}
+	RegisterFormat("KV", ConfigDecoderFunc(decodeKeyValue))
+	defer func() {
+		decodersMutex.Lock()
+		delete(decoders, "kv")
+		decodersMutex.Unlock()
+	}()
+	err = Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "registered" {
+		t.Error("foo was not registered")
+	}
+}
+
+func TestDecoderPositions(t *testing.T) {
+	tests := []struct {
+		decoder  ConfigDecoderFunc
+		contents string
+		err      string
+	}{
+		{decodeJson, "{\n  \"foo\": \"bar\",\n  \"wibble\": 1\n}", "wibble at line 3, column 3"},
+		{decodeJson, "{\n  \"section\": {\n    \"wibble\": 1\n  }\n}", "wibble at line 3, column 5"},
+		{decodeJson, "{\n  \"foo\": 1\n}", "expected type string, got number at line 2, column 3"},
+		{decodeJson, "{\n  \"foo\": bar\n}", "invalid character 'b' looking for beginning of value at line 2, column 10"},
+		{decodeYaml, "foo: bar\nsection:\n  wibble: 1\n", "wibble at line 3, column 3"},
+		{decodeYaml, "foo: bar\nsection: 1\n", "expected an object for section at line 2, column 1"},
+		{decodeToml, "foo = \"bar\"\n[section]\nwibble = 1\n", "wibble at line 3, column 1"},
+	}
+	for _, test := range tests {
+		cfg := struct {
+			Foo     string
+			Section struct {
+				Bar string
+			}
+		}{}
+		c := cfgApe{cfg: &cfg}
+		if err := c.parseStructIntoSettings(); err != nil {
+			t.Fatal(err)
+		}
+		err := c.decodeConfigFile("test", test.decoder, strings.NewReader(test.contents))
+		if err == nil {
+			t.Errorf("expected error decoding %q", test.contents)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("expected error decoding %q to contain %q, got: %s", test.contents, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package configape
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ManPage returns a roff man page (section 1) for cfg, with NAME, SYNOPSIS, OPTIONS, COMMANDS,
+// ENVIRONMENT and FILES sections. The HelpHeader is used as the description of the program.
+func ManPage(cfg interface{}, options *Options) (string, error) {
+	c, err := docsApe(cfg, options)
+	if err != nil {
+		return "", err
+	}
+	return c.makeManPage(), nil
+}
+
+// MarkdownReference returns a Markdown reference for cfg, with a table of every setting showing
+// the command line argument, environment variable, config file key, default, if it is required,
+// and the help.
+func MarkdownReference(cfg interface{}, options *Options) (string, error) {
+	c, err := docsApe(cfg, options)
+	if err != nil {
+		return "", err
+	}
+	return c.makeMarkdownReference(), nil
+}
+
+func docsApe(cfg interface{}, options *Options) (*cfgApe, error) {
+	c := &cfgApe{}
+	c.cfg = cfg
+	if options == nil {
+		options = &Options{}
+	}
+	c.options = *options
+	if c.options.Name == "" {
+		c.options.Name = os.Args[0]
+	}
+	err := c.parseStructIntoSettings()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// The global settings, with those in subsections in place of the subsection.
+func (c *cfgApe) docsSettings() []*HelpSetting {
+	return flattenHelpSettings(c.helpSettings(c.settings, "", "", "", false))
+}
+
+// All the commands, depth first.
+func (c *cfgApe) docsCommands() []*HelpCommand {
+	return flattenHelpCommands(c.helpCommands(c.settings, ""))
+}
+
+// The config files that are read, in order
+func (c *cfgApe) docsConfigFiles() []string {
+	if c.options.DisableConfigFile {
+		return nil
+	}
+	return c.configFileChain("")
+}
+
+// Escapes text for roff, backslashes and lines starting with a control character.
+func roffEscape(str string) string {
+	str = strings.ReplaceAll(str, `\`, `\e`)
+	str = strings.ReplaceAll(str, "-", `\-`)
+	lines := strings.Split(str, "\n")
+	for idx, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[idx] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// The flag in bold, with the value names in italics, eg \fB\-\-port\fR \fIPort\fR
+func roffFlag(flag string) string {
+	flag = roffEscape(flag)
+	flag = strings.NewReplacer(" <", `\fR \fI`, ">", `\fR\fB`).Replace(flag)
+	return `\fB` + flag + `\fR`
+}
+
+func (c *cfgApe) makeManPage() string {
+	name := c.options.Name
+	if idx := strings.LastIndex(name, "/"); idx != -1 {
+		name = name[idx+1:]
+	}
+	version := c.options.Version
+	if version == "" {
+		version = "0.0.0"
+	}
+	commands := c.docsCommands()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, ".TH %s 1 \"\" \"%s v%s\" \"User Commands\"\n", roffEscape(strings.ToUpper(name)), roffEscape(name), roffEscape(version))
+	b.WriteString(".SH NAME\n")
+	if header := firstLine(c.options.HelpHeader); header != "" {
+		fmt.Fprintf(&b, "%s \\- %s\n", roffEscape(name), roffEscape(header))
+	} else {
+		fmt.Fprintf(&b, "%s\n", roffEscape(name))
+	}
+
+	b.WriteString(".SH SYNOPSIS\n")
+	fmt.Fprintf(&b, ".B %s\n", roffEscape(name))
+	b.WriteString("[\\fIOPTIONS\\fR]\n")
+	if len(commands) > 0 {
+		b.WriteString("\\fICOMMAND\\fR [\\fICOMMAND OPTIONS\\fR]\n")
+	}
+	if c.settings.FindRemaining() != nil {
+		b.WriteString("[\\fIARGS\\fR...]\n")
+	}
+	if c.options.HelpHeader != "" {
+		b.WriteString(".SH DESCRIPTION\n")
+		fmt.Fprintf(&b, "%s\n", roffEscape(c.options.HelpHeader))
+	}
+
+	settings := c.docsSettings()
+	b.WriteString(".SH OPTIONS\n")
+	writeRoffOptions(&b, settings)
+	if !c.options.DisableHelp {
+		b.WriteString(".TP\n\\fB\\-\\-help\\fR\nShow the help.\n")
+	}
+	if !c.options.DisableVersion {
+		b.WriteString(".TP\n\\fB\\-\\-version\\fR\nShow the version.\n")
+	}
+
+	if len(commands) > 0 {
+		b.WriteString(".SH COMMANDS\n")
+		for _, command := range commands {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\n", roffEscape(command.Path))
+			if command.Help != "" {
+				fmt.Fprintf(&b, "%s\n", roffEscape(command.Help))
+			}
+			if settings := flattenHelpSettings(command.Settings); len(settings) > 0 {
+				b.WriteString(".RS\n")
+				writeRoffOptions(&b, settings)
+				b.WriteString(".RE\n")
+			}
+		}
+	}
+
+	environment := false
+	for _, setting := range settings {
+		if setting.Env == "" {
+			continue
+		}
+		if !environment {
+			b.WriteString(".SH ENVIRONMENT\n")
+			environment = true
+		}
+		fmt.Fprintf(&b, ".TP\n\\fB%s\\fR\n", roffEscape(setting.Env))
+		if help := setting.helpAndConstraints(); help != "" {
+			fmt.Fprintf(&b, "%s\n", roffEscape(help))
+		}
+	}
+
+	if files := c.docsConfigFiles(); len(files) > 0 {
+		b.WriteString(".SH FILES\n")
+		for idx, file := range files {
+			fmt.Fprintf(&b, ".TP\n\\fI%s\\fR\n", roffEscape(file))
+			if idx == 0 {
+				b.WriteString("The config file.\n")
+			} else {
+				b.WriteString("Overrides the config files above.\n")
+			}
+		}
+	}
+	if c.options.HelpFooter != "" {
+		b.WriteString(".SH NOTES\n")
+		fmt.Fprintf(&b, "%s\n", roffEscape(c.options.HelpFooter))
+	}
+	return b.String()
+}
+
+func writeRoffOptions(b *strings.Builder, settings []*HelpSetting) {
+	for _, setting := range settings {
+		if setting.Flag == "" {
+			continue
+		}
+		fmt.Fprintf(b, ".TP\n%s\n", roffFlag(setting.usage()))
+		var details []string
+		if help := setting.helpAndConstraints(); help != "" {
+			details = append(details, help)
+		}
+		if setting.Required {
+			details = append(details, "(required)")
+		}
+		if setting.Default != "" {
+			details = append(details, fmt.Sprintf("(default: %s)", setting.Default))
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(b, "%s\n", roffEscape(strings.Join(details, " ")))
+		}
+	}
+}
+
+// Escapes text for a Markdown table cell
+func markdownCell(str string) string {
+	str = strings.ReplaceAll(str, "|", `\|`)
+	return strings.ReplaceAll(str, "\n", "<br>")
+}
+
+func markdownCode(str string) string {
+	if str == "" {
+		return ""
+	}
+	return "`" + markdownCell(str) + "`"
+}
+
+func (c *cfgApe) makeMarkdownReference() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", c.options.Name)
+	if c.options.HelpHeader != "" {
+		fmt.Fprintf(&b, "%s\n\n", c.options.HelpHeader)
+	}
+	writeMarkdownTable(&b, c.docsSettings(), true)
+
+	if files := c.docsConfigFiles(); len(files) > 0 {
+		b.WriteString("\n## Config files\n\n")
+		b.WriteString("Read in order, later files override earlier ones.\n\n")
+		for _, file := range files {
+			fmt.Fprintf(&b, "- `%s`\n", file)
+		}
+	}
+
+	commands := c.docsCommands()
+	if len(commands) > 0 {
+		b.WriteString("\n## Commands\n")
+		for _, command := range commands {
+			fmt.Fprintf(&b, "\n### %s %s\n\n", c.options.Name, command.Path)
+			if command.Help != "" {
+				fmt.Fprintf(&b, "%s\n\n", command.Help)
+			}
+			if settings := flattenHelpSettings(command.Settings); len(settings) > 0 {
+				writeMarkdownTable(&b, settings, false)
+			}
+		}
+	}
+	if c.options.HelpFooter != "" {
+		fmt.Fprintf(&b, "\n%s\n", c.options.HelpFooter)
+	}
+	return b.String()
+}
+
+// Writes the table of settings, the global settings also have the environment and config key.
+func writeMarkdownTable(b *strings.Builder, settings []*HelpSetting, global bool) {
+	if global {
+		b.WriteString("| Flag | Environment | Config key | Default | Required | Description |\n")
+		b.WriteString("| --- | --- | --- | --- | --- | --- |\n")
+	} else {
+		b.WriteString("| Flag | Default | Required | Description |\n")
+		b.WriteString("| --- | --- | --- | --- |\n")
+	}
+	for _, setting := range settings {
+		required := ""
+		if setting.Required {
+			required = "yes"
+		}
+		if global {
+			fmt.Fprintf(b, "| %s | %s | %s | %s | %s | %s |\n", markdownCode(setting.usage()), markdownCode(setting.Env), markdownCode(setting.Key),
+				markdownCode(setting.Default), required, markdownCell(setting.helpAndConstraints()))
+		} else {
+			fmt.Fprintf(b, "| %s | %s | %s | %s |\n", markdownCode(setting.usage()), markdownCode(setting.Default), required, markdownCell(setting.helpAndConstraints()))
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package configape
+
+import (
+	"strings"
+	"testing"
+)
+
+type docsConfig struct {
+	Verbose  bool   `short:"v" help:"Be verbose"`
+	Level    string `oneof:"debug,info" default:"info" help:"Log level"`
+	Password string `secret:"true" required:"true" default:"hunter2"`
+	Token    string `cli:"-" help:"API token"`
+	Database struct {
+		Host string `help:"Database host" env:"dbhost"`
+	}
+	Serve *struct {
+		Port int `short:"p" help:"Port | number"`
+	} `cfgtype:"command" help:"Run the server"`
+}
+
+func TestMarkdownReference(t *testing.T) {
+	md, err := MarkdownReference(&docsConfig{}, &Options{Name: "tool", EnvironmentPrefix: "TOOL_", ConfigFilenames: []string{"/etc/tool.toml", "~/.tool.toml"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"# tool\n",
+		"| `--verbose, -v` | `TOOL_VERBOSE` | `verbose` |  |  | Be verbose |\n",
+		"| `--level <Level>` | `TOOL_LEVEL` | `level` | `info` |  | Log level (one of: debug, info) |\n",
+		"| `--password <Password>` | `TOOL_PASSWORD` | `password` | `******` | yes |  |\n",
+		"|  | `TOOL_TOKEN` | `token` |  |  | API token |\n",
+		"| `--database-host <Host>` | `TOOL_DATABASE_DBHOST` | `database.host` |  |  | Database host |\n",
+		"- `/etc/tool.toml`\n- `~/.tool.toml`\n",
+		"### tool serve\n\nRun the server\n",
+		"| `--port <Port>, -p <Port>` |  |  | Port \\| number |\n",
+	}
+	for _, str := range expected {
+		if !strings.Contains(md, str) {
+			t.Errorf("expected the reference to contain %q:\n%s", str, md)
+		}
+	}
+	if strings.Contains(md, "hunter2") {
+		t.Errorf("expected the secret default to be redacted:\n%s", md)
+	}
+}
+
+func TestManPage(t *testing.T) {
+	man, err := ManPage(&docsConfig{}, &Options{Name: "/usr/bin/tool", Version: "1.2.3", HelpHeader: "Does tool things"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		".TH TOOL 1 \"\" \"tool v1.2.3\" \"User Commands\"\n",
+		".SH NAME\ntool \\- Does tool things\n",
+		".SH SYNOPSIS\n.B tool\n[\\fIOPTIONS\\fR]\n\\fICOMMAND\\fR",
+		".SH OPTIONS\n.TP\n\\fB\\-\\-verbose, \\-v\\fR\nBe verbose\n",
+		".TP\n\\fB\\-\\-level\\fR \\fILevel\\fR\\fB\\fR\nLog level (one of: debug, info) (default: info)\n",
+		".SH COMMANDS\n.TP\n\\fBserve\\fR\nRun the server\n.RS\n",
+		".SH ENVIRONMENT\n",
+		".TP\n\\fBCFG_TOKEN\\fR\nAPI token\n",
+		".SH FILES\n.TP\n\\fIconfig.json\\fR\n",
+	}
+	for _, str := range expected {
+		if !strings.Contains(man, str) {
+			t.Errorf("expected the man page to contain %q:\n%s", str, man)
+		}
+	}
+	// The token can't be set on the command line
+	if strings.Contains(man, "\\-\\-token") {
+		t.Errorf("expected no --token option:\n%s", man)
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"strings"
 )
 
-func (c *cfgApe) processEnvironment() error {
+// The prefix of the environment variables, CFG_ unless set in the options.
+func (c *cfgApe) environmentPrefix() string {
 	prefix := c.options.EnvironmentPrefix
 	if prefix == "" {
 		prefix = "CFG_"
@@ -15,47 +16,97 @@ func (c *cfgApe) processEnvironment() error {
 	if prefix == "!" {
 		prefix = ""
 	}
+	return prefix
+}
 
-	env := os.Environ()
-	for _, envVar := range env {
-		parts := strings.SplitN(envVar, "=", 2)
-		val := ""
-		originalName := parts[0]
-		if !strings.HasPrefix(originalName, prefix) {
-			continue
-		}
-		if len(parts) == 2 {
-			val = parts[1]
-		}
+// The environment variable for the setting, sections is the names of the subsections it is in
+// (eg DATABASE_). Empty if the setting can't be set from the environment.
+func (c *cfgApe) environmentName(setting *cfgSetting, sections string) string {
+	if c.options.DisableEnviornment || setting.envName == "-" {
+		return ""
+	}
+	name := setting.envName
+	if name == "" {
+		name = setting.configKey()
+	}
+	return c.environmentPrefix() + strings.ToUpper(sections+name)
+}
 
-		name := strings.TrimPrefix(originalName, prefix)
-		name = strings.ToLower(name)
+func (c *cfgApe) processEnvironment() error {
+	prefix := c.environmentPrefix()
 
-		setting := c.settings.FindRecursive(name, "env")
-		if setting == nil {
-			continue
-		}
-		// Boolean here is handled as if the environment variable is set to empty, or
-		// if its value is "true" or "1", then it's true, otherwise it's false
-		if setting.reflectType.Kind() == reflect.Bool || setting.fieldType == fieldTypeFlag {
-			if val == "true" || val == "1" || val == "" {
-				val = "true"
-			} else {
-				val = "false"
+	errs := &MultiError{}
+	// Secrets can also be read from the file named by a _FILE variable (eg CFG_PASSWORD_FILE), as
+	// docker and kubernetes do. These are done first so that setting the secret directly wins.
+	for _, secretFiles := range []bool{true, false} {
+		env := os.Environ()
+		for _, envVar := range env {
+			parts := strings.SplitN(envVar, "=", 2)
+			val := ""
+			originalName := parts[0]
+			if !strings.HasPrefix(originalName, prefix) {
+				continue
+			}
+			if len(parts) == 2 {
+				val = parts[1]
 			}
+
+			name := strings.TrimPrefix(originalName, prefix)
+			name = strings.ToLower(name)
+
+			setting := c.settings.FindRecursive(name, "env")
+			if secretFiles {
+				if setting != nil || !strings.HasSuffix(name, "_file") {
+					continue
+				}
+				setting = c.settings.FindRecursive(strings.TrimSuffix(name, "_file"), "env")
+				if setting == nil || !setting.secret {
+					continue
+				}
+				var err error
+				val, err = readSecretFile(val)
+				if err != nil {
+					errs.add(fmt.Errorf("failed to read environment %s into cfg.%s: %s", originalName, setting.name, err))
+					continue
+				}
+			}
+			if setting == nil {
+				continue
+			}
+			errs.add(c.setEnvironment(setting, originalName, val))
 		}
+	}
+	return errs.errorOrNil()
+}
 
-		var err error
-		if setting.fieldType == fieldTypeList {
-			values := strings.Split(val, ",")
-			setting.reflectValue, err = strListToType(setting.reflectType, values)
+func (c *cfgApe) setEnvironment(setting *cfgSetting, originalName string, val string) error {
+	// Boolean here is handled as if the environment variable is set to empty, or
+	// if its value is "true" or "1", then it's true, otherwise it's false
+	if setting.reflectType.Kind() == reflect.Bool || setting.fieldType == fieldTypeFlag {
+		if val == "true" || val == "1" || val == "" {
+			val = "true"
 		} else {
-			// debugf("Setting %s to %s\n", setting.name, val)
-			setting.reflectValue, err = strToType(setting.reflectType, val)
+			val = "false"
 		}
-		setting.valueSet = true
-		if err != nil {
-			return fmt.Errorf("failed to parse environment %s into cfg.%s: %s", originalName, setting.name, err)
+	}
+
+	var err error
+	if setting.fieldType == fieldTypeList {
+		values := strings.Split(val, ",")
+		setting.reflectValue, err = strListToType(setting.reflectType, values)
+	} else {
+		// debugf("Setting %s to %s\n", setting.name, val)
+		setting.reflectValue, err = setting.parseValue(val)
+	}
+	setting.valueSet = true
+	setting.whereSet = Source{Kind: SourceEnvironment, Name: originalName}
+	if err != nil {
+		return &ErrParseValue{
+			Setting: setting.path,
+			Name:    setting.name,
+			Source:  setting.whereSet,
+			Input:   setting.redact(val),
+			Err:     setting.redactError(err, val),
 		}
 	}
 	return nil
@@ -2,6 +2,7 @@ package configape
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -175,3 +176,39 @@ func TestEnvironmentPointers(t *testing.T) {
 	os.Unsetenv("CFG_FOO")
 	os.Unsetenv("CFG_CUSTOM")
 }
+
+func TestEnvironmentTag(t *testing.T) {
+	type config struct {
+		Host     string `env:"DB_HOST"`
+		User     string `env:"db_user"`
+		Database struct {
+			Port int `env:"dbport"`
+		}
+	}
+	options := Options{DisableConfigFile: true, DisableCommandLine: true, HelpShowNames: true}
+
+	// The names shown in the help are the ones that are read
+	help, err := Help(&config{}, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"CFG_DB_HOST", "CFG_DB_USER", "CFG_DATABASE_DBPORT"} {
+		if !strings.Contains(help, "env: "+name) {
+			t.Errorf("expected %s in the help:\n%s", name, help)
+		}
+	}
+	os.Setenv("CFG_DB_HOST", "dbhost")
+	os.Setenv("CFG_DB_USER", "bob")
+	os.Setenv("CFG_DATABASE_DBPORT", "5432")
+	defer os.Unsetenv("CFG_DB_HOST")
+	defer os.Unsetenv("CFG_DB_USER")
+	defer os.Unsetenv("CFG_DATABASE_DBPORT")
+	cfg := config{}
+	err = Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Host != "dbhost" || cfg.User != "bob" || cfg.Database.Port != 5432 {
+		t.Errorf("expected the values from the environment, got %+v", cfg)
+	}
+}
@@ -0,0 +1,167 @@
+package configape
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrHelpRequested is returned by Apply when --help was on the command line, after the help has
+// been printed. The program should normally exit with status 0.
+var ErrHelpRequested = errors.New("help requested")
+
+// ErrVersionRequested is returned by Apply when --version was on the command line, after the
+// version has been printed. The program should normally exit with status 0.
+var ErrVersionRequested = errors.New("version requested")
+
+// ErrCompletionRequested is returned by Apply when --completion was on the command line, after
+// the completion script has been printed.
+var ErrCompletionRequested = errors.New("completion requested")
+
+// ErrGenerateConfigRequested is returned by Apply when --generate-config was on the command line,
+// after the sample config file has been printed.
+var ErrGenerateConfigRequested = errors.New("generate config requested")
+
+// MultiError holds every problem that was found with the configuration, so that they can all
+// be fixed at once. It works with errors.Is and errors.As, which check each of the Errors.
+type MultiError struct {
+	Errors []error
+}
+
+func (m *MultiError) Error() string {
+	msgs := make([]string, len(m.Errors))
+	for idx, err := range m.Errors {
+		msgs[idx] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
+
+// Add the error (if any), the errors in a MultiError are added individually.
+func (m *MultiError) add(err error) {
+	if err == nil {
+		return
+	}
+	if multi, ok := err.(*MultiError); ok {
+		m.Errors = append(m.Errors, multi.Errors...)
+		return
+	}
+	m.Errors = append(m.Errors, err)
+}
+
+// Returns nil if there are no errors, so that a nil *MultiError is never returned as an error.
+func (m *MultiError) errorOrNil() error {
+	if len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+// ErrRequired is a required setting that wasn't set.
+type ErrRequired struct {
+	Setting string // The config key path of the setting, eg database.host
+	Name    string // The name of the setting as used in messages
+}
+
+func (e *ErrRequired) Error() string {
+	return fmt.Sprintf("required setting %s not set", e.Name)
+}
+
+// ErrUnknownArgument is a command line argument that doesn't match any setting.
+type ErrUnknownArgument struct {
+	Argument string // The argument as given, without any value, eg --wibble for --wibble=1
+}
+
+func (e *ErrUnknownArgument) Error() string {
+	return fmt.Sprintf("unknown command line argument: %s", e.Argument)
+}
+
+// ErrUnknownConfigKey is a key in a config file that doesn't match any setting.
+type ErrUnknownConfigKey struct {
+	Key    string // The path of the key, eg database.wibble
+	Source Source // The config file, line and column of the key
+}
+
+func (e *ErrUnknownConfigKey) Error() string {
+	return fmt.Sprintf("error parsing config file %s: unknown setting in config file: %s%s", e.Source.Name, e.Key, positionString(e.Source.Line, e.Source.Column))
+}
+
+// ErrParseValue is a value that couldn't be converted into the type of the setting.
+type ErrParseValue struct {
+	Setting string // The config key path of the setting, eg database.port
+	Name    string // The name of the setting as used in messages
+	Source  Source // Where the value came from
+	Input   string // The value that failed to parse, redacted if the setting is a secret
+	Err     error  // Why it failed
+}
+
+func (e *ErrParseValue) Error() string {
+	switch e.Source.Kind {
+	case SourceDefault:
+		return fmt.Sprintf("error setting defaults: failed to parse default value for %s: %s", e.Name, e.Err)
+	case SourceFile:
+		return fmt.Sprintf("error parsing config file %s: %s%s", e.Source.Name, e.Err, positionString(e.Source.Line, e.Source.Column))
+	case SourceEnvironment:
+		return fmt.Sprintf("failed to parse environment %s into cfg.%s: %s", e.Source.Name, e.Name, e.Err)
+	default:
+		// The name is the flag, unless the Source was made with the whole argument
+		if strings.Contains(e.Source.Name, "=") {
+			return fmt.Sprintf("failed to parse %s into cfg.%s: %s", e.Source.Name, e.Name, e.Err)
+		}
+		return fmt.Sprintf("failed to parse %s=%s into cfg.%s: %s", e.Source.Name, e.Input, e.Name, e.Err)
+	}
+}
+
+func (e *ErrParseValue) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidValue is a value that was parsed, but failed the validation tags of the setting.
+type ErrInvalidValue struct {
+	Setting string // The config key path of the setting, eg database.port
+	Source  Source // Where the value came from
+	Err     error  // Why it is invalid
+}
+
+func (e *ErrInvalidValue) Error() string {
+	return fmt.Sprintf("invalid value for %s from %s: %s", e.Setting, e.Source, e.Err)
+}
+
+func (e *ErrInvalidValue) Unwrap() error {
+	return e.Err
+}
+
+// ErrConfigSyntax is a config file that couldn't be decoded. A ConfigDecoder can return one
+// of these to report where the problem is, the File is filled in for it.
+type ErrConfigSyntax struct {
+	File   string // The config file
+	Line   int    // The line of the problem, zero if unknown
+	Column int    // The column of the problem, zero if unknown
+	Err    error  // The problem
+}
+
+func (e *ErrConfigSyntax) Error() string {
+	msg := fmt.Sprintf("%s%s", e.Err, positionString(e.Line, e.Column))
+	if e.File != "" {
+		msg = fmt.Sprintf("error parsing config file %s: %s", e.File, msg)
+	}
+	return msg
+}
+
+func (e *ErrConfigSyntax) Unwrap() error {
+	return e.Err
+}
+
+// Returns " at line X, column Y" for the parts of the position that are known.
+func positionString(line, column int) string {
+	if line == 0 {
+		return ""
+	}
+	if column == 0 {
+		return fmt.Sprintf(" at line %d", line)
+	}
+	return fmt.Sprintf(" at line %d, column %d", line, column)
+}
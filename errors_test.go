@@ -0,0 +1,175 @@
+package configape
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	cfg := struct {
+		Name     string `required:"true"`
+		Port     int    `max:"10"`
+		Count    int
+		Database struct {
+			Host string `required:"true"`
+		}
+	}{}
+	os.Setenv("CFG_COUNT", "lots")
+	defer os.Unsetenv("CFG_COUNT")
+	buffer := bytes.NewBuffer([]byte{})
+	err := Apply(&cfg, &Options{
+		ConfigFilename:  "test.json",
+		cfgFileContents: `{"wibble": 1, "port": "ten", "wobble": 2}`,
+		HelpWriter:      buffer,
+		osArgs:          []string{"cfgape", "--unknown", "--port=20"},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	var multi *MultiError
+	if !errors.As(err, &multi) {
+		t.Fatalf("expected a MultiError: %T", err)
+	}
+	expected := []string{
+		"error parsing config file test.json: unknown setting in config file: wibble at line 1, column 2",
+		"error parsing config file test.json: expected type int, got string at line 1, column 15",
+		"error parsing config file test.json: unknown setting in config file: wobble at line 1, column 30",
+		"failed to parse environment CFG_COUNT into cfg.Count",
+		"unknown command line argument: --unknown",
+		"required setting Name not set",
+		"required setting database.Host not set",
+		"invalid value for port from command line --port: must be at most 10",
+	}
+	if len(multi.Errors) != len(expected) {
+		t.Fatalf("expected %d errors, got %d:\n%s", len(expected), len(multi.Errors), err)
+	}
+	for idx, msg := range expected {
+		if !strings.HasPrefix(multi.Errors[idx].Error(), msg) {
+			t.Errorf("expected error %d to start with %q, got: %s", idx, msg, multi.Errors[idx])
+		}
+	}
+	if strings.Count(buffer.String(), "Usage:") != 1 {
+		t.Errorf("expected the help to be printed once:\n%s", buffer.String())
+	}
+
+	// A nil MultiError is never returned
+	var empty MultiError
+	if empty.errorOrNil() != nil {
+		t.Error("expected nil")
+	}
+}
+
+func TestTypedErrors(t *testing.T) {
+	cfg := struct {
+		Password string `secret:"true"`
+		Port     int
+		Database struct {
+			Host string `required:"true"`
+			Pool int
+		}
+	}{}
+	os.Setenv("CFG_PORT", "eighty")
+	defer os.Unsetenv("CFG_PORT")
+	err := Apply(&cfg, &Options{
+		ConfigFilename:               "test.yaml",
+		cfgFileContents:              "database:\n  pool: lots\n  wibble: 1\n",
+		DisableHelpOnMissingRequired: true,
+		osArgs:                       []string{"cfgape", "--password=hunter2", "--nope"},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	var required *ErrRequired
+	if !errors.As(err, &required) || required.Setting != "database.host" {
+		t.Errorf("expected ErrRequired for database.host, got: %v", required)
+	}
+	var unknownArg *ErrUnknownArgument
+	if !errors.As(err, &unknownArg) || unknownArg.Argument != "--nope" {
+		t.Errorf("expected ErrUnknownArgument for --nope, got: %v", unknownArg)
+	}
+	var unknownKey *ErrUnknownConfigKey
+	if !errors.As(err, &unknownKey) || unknownKey.Key != "database.wibble" || unknownKey.Source.Name != "test.yaml" || unknownKey.Source.Line != 3 || unknownKey.Source.Column != 3 {
+		t.Errorf("expected ErrUnknownConfigKey for database.wibble at test.yaml:3:3, got: %+v", unknownKey)
+	}
+
+	// Every failed value is found, not just the first
+	parsed := map[string]*ErrParseValue{}
+	for _, e := range err.(*MultiError).Errors {
+		var parseErr *ErrParseValue
+		if errors.As(e, &parseErr) {
+			parsed[parseErr.Setting] = parseErr
+		}
+	}
+	if p := parsed["database.pool"]; p == nil || p.Source.Kind != SourceFile || p.Source.Line != 2 || p.Input != "lots" {
+		t.Errorf("expected ErrParseValue for database.pool from the file, got: %+v", p)
+	}
+	if p := parsed["port"]; p == nil || p.Source.Kind != SourceEnvironment || p.Source.Name != "CFG_PORT" || p.Input != "eighty" {
+		t.Errorf("expected ErrParseValue for port from the environment, got: %+v", p)
+	}
+	if p := parsed["password"]; p != nil {
+		t.Errorf("didn't expect an error for the password: %+v", p)
+	}
+}
+
+func TestTypedErrorRedaction(t *testing.T) {
+	cfg := struct {
+		Pin int `secret:"true"`
+	}{}
+	err := Apply(&cfg, &Options{osArgs: []string{"cfgape", "--pin=12ab"}})
+	var parseErr *ErrParseValue
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("expected ErrParseValue, got: %v", err)
+	}
+	if parseErr.Input != "******" || strings.Contains(parseErr.Error(), "12ab") {
+		t.Errorf("expected the secret to be redacted: %q, %s", parseErr.Input, parseErr)
+	}
+	if parseErr.Source.Kind != SourceCommandLine {
+		t.Errorf("expected the command line source, got %s", parseErr.Source.Kind)
+	}
+}
+
+// The value is only in the error once, whichever way it was given
+func TestParseValueErrorMessage(t *testing.T) {
+	for _, args := range [][]string{{"--port=host:80"}, {"--port", "host:80"}} {
+		cfg := struct{ Port int }{}
+		err := Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: append([]string{"cfgape"}, args...)})
+		expected := "failed to parse --port=host:80 into cfg.Port: invalid integer value: host:80"
+		if err == nil || err.Error() != expected {
+			t.Errorf("%v: expected %q, got: %v", args, expected, err)
+		}
+	}
+	// A Source made with the whole argument isn't repeated either
+	err := &ErrParseValue{Name: "Port", Source: Source{Kind: SourceCommandLine, Name: "--port=x"}, Input: "x", Err: errors.New("bad")}
+	if err.Error() != "failed to parse --port=x into cfg.Port: bad" {
+		t.Errorf("expected the value once, got: %s", err)
+	}
+}
+
+func TestConfigSyntaxError(t *testing.T) {
+	tests := []struct {
+		file     string
+		contents string
+		line     int
+		column   int
+	}{
+		{"test.json", "{\n  \"foo\": 1,,\n}", 2, 12},
+		{"test.toml", "foo = 1\nfoo = 2\n", 2, 1},
+		{"test.yaml", "foo: 1\n bar: 2\n", 2, 0},
+	}
+	for _, test := range tests {
+		cfg := struct{ Foo int }{}
+		err := Apply(&cfg, &Options{ConfigFilename: test.file, cfgFileContents: test.contents, osArgs: []string{"cfgape"}})
+		var syntaxErr *ErrConfigSyntax
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%s: expected ErrConfigSyntax, got: %v", test.file, err)
+			continue
+		}
+		if syntaxErr.File != test.file || syntaxErr.Line != test.line || syntaxErr.Column != test.column {
+			t.Errorf("%s: expected line %d, column %d, got %+v", test.file, test.line, test.column, syntaxErr)
+		}
+	}
+}
@@ -37,20 +37,18 @@ func Example_help() {
 	// Output:
 	// example (v0.0.0)
 	//
-	//   --simple-string <SimpleString>
-	//     This is the help for simple string
+	// Usage: example --required-string <RequiredString> [options]
+	//
+	//   --simple-string <SimpleString>      This is the help for simple string
 	//   --simple-int <SimpleInt> (default: 42)
 	//   --required-string <RequiredString>
 	//   --something <something>
-	//   --short, -s
-	//     This is the help for the short flag
-	//   --config <ConfigFile>
-	//     Load config from this file
+	//   --short, -s                         This is the help for the short flag
+	//   --config <ConfigFile>               Load config from this file
 	//
 	// database
 	//
-	//   --database-host <Host>
-	//     Database host to connect to
-	//   --database-port <Port>
-	//     Database port to use with the database host
+	//   --database-host <Host>              Database host to connect to
+	//   --database-port <Port>              Database port to use with the database
+	//                                       host
 }
@@ -8,9 +8,68 @@ import (
 	"strings"
 )
 
-func (c *cfgApe) parseConfigFile(cfgFile string) error {
-	var fh io.Reader
-	var err error
+// Returns the config files to load, in order. A config file given on the command line
+// (cliFile) replaces the list, unless AppendCommandLineConfigFile is set.
+func (c *cfgApe) configFileChain(cliFile string) []string {
+	var files []string
+	if c.options.ConfigFilename != "" {
+		files = append(files, c.options.ConfigFilename)
+	}
+	files = append(files, c.options.ConfigFilenames...)
+	if len(files) == 0 {
+		files = []string{"config.json"}
+	}
+	if cliFile != "" {
+		if c.options.AppendCommandLineConfigFile {
+			files = append(files, cliFile)
+		} else {
+			files = []string{cliFile}
+		}
+	}
+	return files
+}
+
+// Expand a leading ~ and any environment variables in the path. If the path refers
+// to an environment variable that isn't set then ok is false, as the path is
+// meaningless. XDG_CONFIG_HOME defaults to ~/.config as per the XDG spec.
+func expandConfigPath(path string) (expanded string, ok bool) {
+	ok = true
+	home, _ := os.UserHomeDir()
+	expanded = os.Expand(path, func(name string) string {
+		value := os.Getenv(name)
+		if value == "" && name == "XDG_CONFIG_HOME" && home != "" {
+			value = filepath.Join(home, ".config")
+		}
+		if value == "" {
+			ok = false
+		}
+		return value
+	})
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") {
+		if home == "" {
+			return expanded, false
+		}
+		expanded = filepath.Join(home, expanded[1:])
+	}
+	return expanded, ok
+}
+
+// Load each of the config files in order, later files override the values from earlier ones.
+// The files that don't exist are skipped, except for cliFile, as it was asked for by name.
+func (c *cfgApe) parseConfigFiles(files []string, cliFile string) error {
+	errs := &MultiError{}
+	for _, file := range files {
+		path, ok := expandConfigPath(file)
+		if !ok {
+			continue
+		}
+		errs.add(c.parseConfigFile(path, cliFile != "" && file == cliFile))
+	}
+	return errs.errorOrNil()
+}
+
+// The type of the config file, from the file extension, or ConfigFileType if there isn't one.
+func (c *cfgApe) configFileType(cfgFile string) string {
 	fileType := "json"
 	if c.options.ConfigFileType != "" {
 		fileType = c.options.ConfigFileType
@@ -20,6 +79,13 @@ func (c *cfgApe) parseConfigFile(cfgFile string) error {
 	if ext != "" {
 		fileType = ext[1:]
 	}
+	return fileType
+}
+
+func (c *cfgApe) parseConfigFile(cfgFile string, required bool) error {
+	var fh io.Reader
+	var err error
+	fileType := c.configFileType(cfgFile)
 
 	if c.options.cfgFileContents != "" {
 		// We instead use this string as the config file
@@ -28,8 +94,8 @@ func (c *cfgApe) parseConfigFile(cfgFile string) error {
 	} else {
 		fh, err = os.Open(cfgFile)
 		if err != nil {
-			// Unable to find file is not an error
-			if os.IsNotExist(err) {
+			// Unable to find file is not an error, unless it had to be there
+			if os.IsNotExist(err) && !required {
 				return nil
 			}
 			return err
@@ -37,12 +103,9 @@ func (c *cfgApe) parseConfigFile(cfgFile string) error {
 		defer fh.(io.Closer).Close()
 
 	}
-	switch fileType {
-	case "json":
-		return c.parseJsonConfigFile(cfgFile, fh)
-	case "yaml":
-		return c.parseYamlConfigFile(cfgFile, fh)
-	default:
+	decoder := c.findDecoder(fileType)
+	if decoder == nil {
 		return fmt.Errorf("unknown config file type: %s", fileType)
 	}
+	return c.decodeConfigFile(cfgFile, decoder, fh)
 }
@@ -1,7 +1,10 @@
 package configape_test
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/zafnz/configape"
@@ -182,3 +185,135 @@ func TestUnknownSetting(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestToml(t *testing.T) {
+	fileContents := `
+foo = "bar"
+flag = true
+
+[subsection]
+foo = "baz"
+list = ["foo", "bar"]
+`
+	fh, _ := os.CreateTemp("", "configape*.toml")
+	defer os.Remove(fh.Name())
+	fh.WriteString(fileContents)
+	cfg := struct {
+		Foo        string
+		Flag       bool
+		Subsection struct {
+			Foo  string
+			List []string
+		}
+	}{}
+	options := configape.Options{
+		DisableEnviornment: true,
+		DisableCommandLine: true,
+		ConfigFilename:     fh.Name(),
+	}
+	err := configape.Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "bar" {
+		t.Errorf("foo was not bar, was: %s", cfg.Foo)
+	}
+	if !cfg.Flag {
+		t.Error("flag was not true")
+	}
+	if cfg.Subsection.Foo != "baz" {
+		t.Errorf("subsection.foo was not baz, was: %s", cfg.Subsection.Foo)
+	}
+	if len(cfg.Subsection.List) != 2 {
+		t.Error("subsection.list was not length 2")
+	}
+}
+
+func TestLayeredConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "system.json"), []byte(`{"foo": "system", "bar": "system", "baz": "system"}`), 0644)
+	os.WriteFile(filepath.Join(dir, "user.yaml"), []byte("bar: user\nbaz: user\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "local.toml"), []byte("baz = \"local\"\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "override.json"), []byte(`{"foo": "override"}`), 0644)
+	os.Setenv("CONFIGAPE_TEST_DIR", dir)
+	defer os.Unsetenv("CONFIGAPE_TEST_DIR")
+
+	type config struct {
+		Foo    string
+		Bar    string
+		Baz    string
+		Config string `cfgtype:"configfile"`
+	}
+	options := configape.Options{
+		DisableEnviornment: true,
+		DisableCommandLine: true,
+		ConfigFilenames: []string{
+			filepath.Join(dir, "system.json"),
+			"$CONFIGAPE_TEST_DIR/user.yaml",
+			filepath.Join(dir, "missing.json"),
+			"$CONFIGAPE_TEST_UNSET_VARIABLE/user.yaml",
+			filepath.Join(dir, "local.toml"),
+		},
+	}
+	cfg := config{}
+	err := configape.Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "system" || cfg.Bar != "user" || cfg.Baz != "local" {
+		t.Errorf("files were not layered correctly: %+v", cfg)
+	}
+
+	// A config file on the command line replaces the chain
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+	os.Args = []string{"test", "--config", filepath.Join(dir, "override.json")}
+	options.DisableCommandLine = false
+	cfg = config{}
+	err = configape.Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "override" || cfg.Bar != "" || cfg.Baz != "" {
+		t.Errorf("command line config file did not replace the chain: %+v", cfg)
+	}
+
+	// Including when the value is after an equals
+	os.Args = []string{"test", "--config=" + filepath.Join(dir, "override.json")}
+	cfg = config{}
+	err = configape.Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "override" || cfg.Bar != "" || cfg.Baz != "" || cfg.Config != filepath.Join(dir, "override.json") {
+		t.Errorf("command line config file with = did not replace the chain: %+v", cfg)
+	}
+
+	// Without a value it's an error
+	os.Args = []string{"test", "--config"}
+	err = configape.Apply(&config{}, &options)
+	if err == nil || err.Error() != "missing value for argument: --config" {
+		t.Errorf("expected a missing value error, got: %v", err)
+	}
+
+	// And has to exist, as it was asked for
+	os.Args = []string{"test", "--config", filepath.Join(dir, "typo.json")}
+	err = configape.Apply(&config{}, &options)
+	if !errors.Is(err, os.ErrNotExist) || !strings.Contains(err.Error(), "typo.json") {
+		t.Errorf("expected an error for the missing config file, got: %v", err)
+	}
+
+	// Or is added on top of it
+	os.Args = []string{"test", "--config", filepath.Join(dir, "override.json")}
+	options.AppendCommandLineConfigFile = true
+	cfg = config{}
+	err = configape.Apply(&cfg, &options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "override" || cfg.Bar != "user" || cfg.Baz != "local" {
+		t.Errorf("command line config file was not added to the chain: %+v", cfg)
+	}
+}
@@ -3,3 +3,5 @@ module github.com/zafnz/configape
 go 1.21.1
 
 require gopkg.in/yaml.v3 v3.0.1
+
+require github.com/pelletier/go-toml/v2 v2.2.4
@@ -23,20 +23,37 @@ func Help(cfg interface{}, options *Options) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return c.makeHelp(), nil
+	err = c.parseHelpTemplate()
+	if err != nil {
+		return "", err
+	}
+	return c.makeHelp()
 }
 
-func (c *cfgApe) printHelp() {
+func (c *cfgApe) printHelp() error {
 	if c.options.Help != nil {
-		c.options.Help(c.makeHelp())
-		return
+		help, err := c.makeHelp()
+		if err != nil {
+			return err
+		}
+		c.options.Help(help)
+		return nil
 	}
 	var fh io.Writer
 	fh = os.Stderr
 	if c.options.HelpWriter != nil {
 		fh = c.options.HelpWriter
 	}
-	fmt.Fprint(fh, c.makeHelp())
+	width := c.options.HelpWidth
+	if file, ok := fh.(*os.File); ok && width == 0 {
+		width = terminalWidth(file)
+	}
+	help, err := c.makeHelpWidth(width)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(fh, help)
+	return nil
 }
 
 func (c *cfgApe) printVersion() {
@@ -55,72 +72,399 @@ func (c *cfgApe) printVersion() {
 	}
 }
 
-func (c *cfgApe) makeHelp() string {
-	result := ""
+func (c *cfgApe) makeHelp() (string, error) {
+	return c.makeHelpWidth(c.options.HelpWidth)
+}
+
+// Makes the help, wrapped to the width (80 if not set)
+func (c *cfgApe) makeHelpWidth(width int) (string, error) {
+	var b strings.Builder
+	err := c.helpTemplate.Execute(&b, c.helpModel(width))
+	if err != nil {
+		return "", fmt.Errorf("error rendering help template: %w", err)
+	}
+	return b.String(), nil
+}
+
+// Builds the model that the help template is rendered with.
+func (c *cfgApe) helpModel(width int) *HelpModel {
+	if width <= 0 {
+		width = 80
+	}
 	if c.options.Name == "" {
 		c.options.Name = os.Args[0]
 	}
 	if c.options.Version == "" {
 		c.options.Version = "0.0.0"
 	}
-	result += fmt.Sprintf("%s (v%s)\n\n", c.options.Name, c.options.Version)
+	model := &HelpModel{
+		Name:     c.options.Name,
+		Version:  c.options.Version,
+		Usage:    c.usageLine(),
+		Header:   c.options.HelpHeader,
+		Footer:   c.options.HelpFooter,
+		Settings: c.helpSettings(c.settings, "", "", "", false),
+		Width:    width,
+	}
 
-	if c.options.HelpHeader != "" {
-		result += fmt.Sprintf("%s\n\n", c.options.HelpHeader)
+	// If a command was selected, then the help is for that command, followed by the global options
+	scope := model.Settings
+	commands := c.helpCommands(c.settings, "")
+	if len(c.commands) > 0 {
+		command := c.commands[len(c.commands)-1]
+		model.Command = c.helpCommand(command, strings.Join(c.commandPath()[:len(c.commands)-1], " "))
+		scope = model.Command.Settings
+		commands = model.Command.Commands
 	}
+	model.Commands = commands
 
-	result += makeHelp(c.settings, "")
+	sections := c.helpSections(scope)
+	if commands := commandsHelpSection(model.Commands); commands != nil {
+		sections = append(sections, commands)
+	}
+	if len(c.commands) > 0 {
+		global := c.helpSections(model.Settings)
+		for _, command := range c.commands[:len(c.commands)-1] {
+			global = append(global, c.helpSections(c.helpSettings(command.subsection, "", "", "", true))...)
+		}
+		if len(global) > 0 && global[0].Heading == "" {
+			global[0].Heading = "Global options:"
+		} else {
+			global = append([]*HelpSection{{Heading: "Global options:"}}, global...)
+		}
+		sections = append(sections, global...)
+	}
+	if noCli := c.noCliHelpRows(model.Settings); len(noCli) > 0 {
+		sections = append(sections, &HelpSection{Heading: "Environment and config file only:", Rows: noCli})
+	}
+	model.Sections = sections
+	model.Column = helpColumn(sections, width)
+	return model
+}
 
-	if c.options.HelpFooter != "" {
-		result += fmt.Sprintf("\n%s\n", c.options.HelpFooter)
+// Describes the settings for the help model, leaving out the hidden ones, the commands and the
+// remaining arguments. The prefix is for the command line arguments (eg database-) and sections
+// are for the environment variables (eg DATABASE_).
+func (c *cfgApe) helpSettings(settings cfgSettings, prefix string, sections string, group string, inCommand bool) []*HelpSetting {
+	var result []*HelpSetting
+	for i := 0; i < len(settings); i++ {
+		setting := &settings[i]
+		if setting.hidden || setting.isCommand() || setting.name == "*" {
+			continue
+		}
+		result = append(result, c.helpSetting(setting, prefix, sections, group, inCommand))
 	}
 	return result
 }
 
-func makeHelp(settings cfgSettings, prefix string) string {
-	result := ""
+func (c *cfgApe) helpSetting(setting *cfgSetting, prefix string, sections string, group string, inCommand bool) *HelpSetting {
+	help := &HelpSetting{
+		Name:        setting.name,
+		Type:        setting.reflectType.String(),
+		Default:     setting.redact(setting.defaultValue),
+		Constraints: setting.constraintsHelp(),
+		Help:        setting.help,
+		Required:    setting.required,
+		Secret:      setting.secret,
+		Group:       firstNonEmpty(setting.group, group),
+		setting:     setting,
+	}
+	if setting.fieldType == fieldTypeSubsection {
+		prefix, sections := setting.subsectionNames(prefix, sections)
+		help.Settings = c.helpSettings(setting.subsection, prefix, sections, help.Group, inCommand)
+		return help
+	}
+	if setting.cliName != "-" && !c.options.DisableCommandLine {
+		help.Flag = "--" + prefix + setting.cliFlagName()
+		if setting.shortName != "" {
+			help.Short = "-" + setting.shortName
+		}
+		help.Value = setting.valueName()
+	}
+	if !inCommand {
+		help.Env = c.environmentName(setting, sections)
+		if !c.options.DisableConfigFile {
+			help.Key = setting.path
+		}
+	}
+	return help
+}
+
+// The settings, with the settings of each subsection in place of it.
+func flattenHelpSettings(settings []*HelpSetting) []*HelpSetting {
+	var result []*HelpSetting
+	for _, setting := range settings {
+		if setting.isSubsection() {
+			result = append(result, flattenHelpSettings(setting.Settings)...)
+		} else {
+			result = append(result, setting)
+		}
+	}
+	return result
+}
+
+// Describes the commands in the settings for the help model, path is of the enclosing command.
+func (c *cfgApe) helpCommands(settings cfgSettings, path string) []*HelpCommand {
+	var result []*HelpCommand
+	for i := 0; i < len(settings); i++ {
+		setting := &settings[i]
+		if setting.fieldType != fieldTypeCommand || setting.hidden {
+			continue
+		}
+		result = append(result, c.helpCommand(setting, path))
+	}
+	return result
+}
+
+func (c *cfgApe) helpCommand(setting *cfgSetting, path string) *HelpCommand {
+	command := &HelpCommand{
+		Name: setting.commandName(),
+		Path: strings.TrimSpace(path + " " + setting.commandName()),
+		Help: setting.help,
+	}
+	command.Settings = c.helpSettings(setting.subsection, "", "", "", true)
+	command.Commands = c.helpCommands(setting.subsection, command.Path)
+	return command
+}
+
+// All the commands and the commands within them, depth first.
+func flattenHelpCommands(commands []*HelpCommand) []*HelpCommand {
+	var result []*HelpCommand
+	for _, command := range commands {
+		result = append(result, command)
+		result = append(result, flattenHelpCommands(command.Commands)...)
+	}
+	return result
+}
+
+// eg "Usage: tool --name <Name> [options] <command> [args...]"
+func (c *cfgApe) usageLine() string {
+	parts := []string{"Usage:", c.options.Name}
+	parts = append(parts, c.commandPath()...)
+	for _, scope := range c.scopes() {
+		parts = append(parts, requiredUsage(c.helpSettings(scope, "", "", "", true))...)
+	}
+	parts = append(parts, "[options]")
+	if c.scopes()[0].hasCommands() {
+		parts = append(parts, "<command>")
+	}
+	if c.findRemaining() != nil {
+		parts = append(parts, "[args...]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// The required settings that can be set on the command line, eg "--name <Name>"
+func requiredUsage(settings []*HelpSetting) []string {
+	var usage []string
+	for _, setting := range flattenHelpSettings(settings) {
+		if setting.Required && setting.Flag != "" {
+			// Just the long form
+			usage = append(usage, strings.SplitN(setting.usage(), ", ", 2)[0])
+		}
+	}
+	return usage
+}
+
+// The help sections for the settings. The first section has no heading and is the settings at the
+// top level, then each of the subsections, and then the groups.
+func (c *cfgApe) helpSections(settings []*HelpSetting) []*HelpSection {
+	top := &HelpSection{}
+	sections := []*HelpSection{top}
+	groups := []*HelpSection{}
+	c.addHelpRows(&sections, top, &groups, settings)
+
+	// Drop any empty sections (eg subsections where everything is hidden or grouped)
+	result := []*HelpSection{}
+	for _, section := range append(sections, groups...) {
+		if len(section.Rows) > 0 {
+			result = append(result, section)
+		}
+	}
+	return result
+}
 
-	subsections := cfgSettings{}
+// Adds the rows for the settings that can be used on the command line to the section, or the group
+// section if they have a group tag (which subsections pass on to their settings).
+func (c *cfgApe) addHelpRows(all *[]*HelpSection, section *HelpSection, groups *[]*HelpSection, settings []*HelpSetting) {
+	subsections := []*HelpSetting{}
 	for _, setting := range settings {
-		if setting.fieldType == fieldTypeSubsection {
+		if setting.isSubsection() {
 			subsections = append(subsections, setting)
 			continue
 		}
-		name := strings.ToLower(camelCaseToDash(setting.name))
-		if setting.cliName == "-" {
+		if setting.Flag == "" {
 			continue
-		} else if setting.cliName != "" {
-			name = setting.cliName
 		}
-
-		result += fmt.Sprintf("  --%s%s", prefix, name)
-		if setting.fieldType != fieldTypeFlag && setting.fieldType != fieldTypeCounter {
-			result += fmt.Sprintf(" <%s>", setting.name)
+		row := &HelpRow{
+			Usage:   setting.usage() + c.settingDetailsHelp(setting),
+			Setting: setting,
 		}
-		if setting.shortName != "" {
-			result += fmt.Sprintf(", -%s", setting.shortName)
-			if setting.fieldType != fieldTypeFlag && setting.fieldType != fieldTypeCounter {
-				result += fmt.Sprintf(" <%s>", setting.name)
-			}
+		if setting.Help != "" {
+			row.Help = append(row.Help, setting.Help)
 		}
-		if setting.defaultValue != "" {
-			result += fmt.Sprintf(" (default: %s)", setting.defaultValue)
+		if names := c.settingNamesHelp(setting); names != "" {
+			row.Help = append(row.Help, names)
 		}
-		if setting.help != "" {
-			result += fmt.Sprintf("\n    %s\n", setting.help)
-		} else {
-			result += "\n"
+		target := section
+		if setting.Group != "" {
+			target = findGroupSection(groups, setting.Group)
 		}
+		target.Rows = append(target.Rows, row)
 	}
 	// Now do the subsections
 	for _, setting := range subsections {
-		result += fmt.Sprintf("\n%s\n", setting.name)
-		if setting.help != "" {
-			result += fmt.Sprintf("  %s\n", setting.help)
+		sub := &HelpSection{Heading: setting.Name, Description: setting.Help}
+		*all = append(*all, sub)
+		c.addHelpRows(all, sub, groups, setting.Settings)
+	}
+}
+
+func firstNonEmpty(strs ...string) string {
+	for _, str := range strs {
+		if str != "" {
+			return str
 		}
-		result += "\n"
-		name := strings.ToLower(camelCaseToDash(setting.name))
-		result += makeHelp(setting.subsection, fmt.Sprintf("%s%s-", prefix, name))
+	}
+	return ""
+}
+
+// Returns the section for the group, adding it if this is the first setting in the group.
+func findGroupSection(groups *[]*HelpSection, group string) *HelpSection {
+	for _, section := range *groups {
+		if section.Heading == group {
+			return section
+		}
+	}
+	section := &HelpSection{Heading: group}
+	*groups = append(*groups, section)
+	return section
+}
+
+// The default, constraints and if it's required, for after the name of the setting.
+func (c *cfgApe) settingDetailsHelp(setting *HelpSetting) string {
+	result := ""
+	if setting.Default != "" {
+		result += fmt.Sprintf(" (default: %s)", setting.Default)
+	}
+	if setting.Constraints != "" {
+		result += " " + setting.Constraints
+	}
+	if setting.Required && c.options.HelpShowNames {
+		result += " (required)"
 	}
 	return result
 }
+
+// The environment variable and config file key of the setting, eg
+// "env: CFG_DATABASE_HOST, config: database.host", if HelpShowNames is set.
+func (c *cfgApe) settingNamesHelp(setting *HelpSetting) string {
+	if !c.options.HelpShowNames {
+		return ""
+	}
+	var names []string
+	if setting.Env != "" {
+		names = append(names, "env: "+setting.Env)
+	}
+	if setting.Key != "" {
+		names = append(names, "config: "+setting.Key)
+	}
+	return strings.Join(names, ", ")
+}
+
+// The rows for the settings that can't be used on the command line (cli:"-"), if HelpShowNames
+// is set, as otherwise they wouldn't appear in the help at all.
+func (c *cfgApe) noCliHelpRows(settings []*HelpSetting) []*HelpRow {
+	if !c.options.HelpShowNames {
+		return nil
+	}
+	var rows []*HelpRow
+	for _, setting := range flattenHelpSettings(settings) {
+		if setting.Flag != "" {
+			continue
+		}
+		names := c.settingNamesHelp(setting)
+		if names == "" {
+			continue
+		}
+		row := &HelpRow{
+			Usage:   names + c.settingDetailsHelp(setting),
+			Setting: setting,
+		}
+		if setting.Help != "" {
+			row.Help = append(row.Help, setting.Help)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// Lists the commands that can be selected.
+func commandsHelpSection(commands []*HelpCommand) *HelpSection {
+	if len(commands) == 0 {
+		return nil
+	}
+	section := &HelpSection{Heading: "Commands:"}
+	for _, command := range commands {
+		row := &HelpRow{Usage: command.Name, Command: command}
+		if command.Help != "" {
+			row.Help = append(row.Help, command.Help)
+		}
+		section.Rows = append(section.Rows, row)
+	}
+	return section
+}
+
+// The column the help of every row is aligned at, the longest usage that fits in half the width.
+func helpColumn(sections []*HelpSection, width int) int {
+	maxColumn := width / 2
+	column := 0
+	for _, section := range sections {
+		for _, row := range section.Rows {
+			if length := len(row.Usage) + 4; length <= maxColumn && length > column {
+				column = length
+			}
+		}
+	}
+	if column == 0 {
+		column = maxColumn
+	}
+	return column
+}
+
+// The placeholder for the value of the setting on the command line, eg <Host>, empty for flags.
+func (s *cfgSetting) valueName() string {
+	switch s.fieldType {
+	case fieldTypeFlag, fieldTypeCounter:
+		return ""
+	case fieldTypeMap:
+		return "<key=value>"
+	}
+	return "<" + s.name + ">"
+}
+
+// How the setting is used on the command line, eg "--database-host <Host>, -d <Host>", empty if
+// it can't be.
+func (h *HelpSetting) usage() string {
+	if h.Flag == "" {
+		return ""
+	}
+	value := ""
+	if h.Value != "" {
+		value = " " + h.Value
+	}
+	usage := h.Flag + value
+	if h.Short != "" {
+		usage += ", " + h.Short + value
+	}
+	return usage
+}
+
+// The help and the constraints, eg "The port (min: 1, max: 65535)"
+func (h *HelpSetting) helpAndConstraints() string {
+	return strings.TrimSpace(h.Help + " " + h.Constraints)
+}
+
+func (h *HelpSetting) isSubsection() bool {
+	return h.setting.fieldType == fieldTypeSubsection
+}
@@ -2,6 +2,7 @@ package configape_test
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -39,8 +40,8 @@ func TestHelp(t *testing.T) {
 		DisableCommandLine: false,
 		HelpWriter:         buffer,
 	})
-	if err != nil {
-		t.Fatal(err)
+	if !errors.Is(err, configape.ErrHelpRequested) {
+		t.Fatalf("expected ErrHelpRequested, got: %v", err)
 	}
 
 	output := buffer.String()
@@ -80,8 +81,9 @@ func ExampleHelp() {
 	// Output:
 	// my-prog (v1.2.3)
 	//
-	//   --foo <foo> (default: baz)
-	//     This is the help for foo
+	// Usage: my-prog [options]
+	//
+	//   --foo <foo> (default: baz)  This is the help for foo
 }
 
 func TestHelpComplex(t *testing.T) {
@@ -115,8 +117,8 @@ func TestHelpComplex(t *testing.T) {
 		DisableCommandLine: false,
 		HelpWriter:         buffer,
 	})
-	if err != nil {
-		t.Fatal(err)
+	if !errors.Is(err, configape.ErrHelpRequested) {
+		t.Fatalf("expected ErrHelpRequested, got: %v", err)
 	}
 
 	output := buffer.String()
@@ -174,8 +176,8 @@ func TestCommandLineHelpSubsections(t *testing.T) {
 		DisableCommandLine: false,
 		HelpWriter:         buffer,
 	})
-	if err != nil {
-		t.Fatal(err)
+	if !errors.Is(err, configape.ErrHelpRequested) {
+		t.Fatalf("expected ErrHelpRequested, got: %v", err)
 	}
 
 	output := buffer.String()
@@ -215,8 +217,8 @@ func TestVersion(t *testing.T) {
 	}
 
 	err := configape.Apply(&cfg, &options)
-	if err != nil {
-		t.Fatal(err)
+	if !errors.Is(err, configape.ErrVersionRequested) {
+		t.Fatalf("expected ErrVersionRequested, got: %v", err)
 	}
 
 	output := buffer.String()
@@ -247,8 +249,8 @@ func TestFullVersion(t *testing.T) {
 
 	buffer.Reset()
 	err := configape.Apply(&cfg, &options)
-	if err != nil {
-		t.Fatal(err)
+	if !errors.Is(err, configape.ErrVersionRequested) {
+		t.Fatalf("expected ErrVersionRequested, got: %v", err)
 	}
 	output := buffer.String()
 	if output != "myprog (v1.2.3)\n" {
@@ -290,3 +292,121 @@ func TestNoVersion(t *testing.T) {
 		t.Error("output was not empty")
 	}
 }
+
+func TestHelpShowNames(t *testing.T) {
+	cfg := struct {
+		Name     string `required:"true" help:"Your name"`
+		Token    string `cli:"-" help:"API token"`
+		Secret   string `cli:"-" env:"-"`
+		Database struct {
+			Host string `default:"localhost"`
+			Port int    `env:"dbport"`
+		}
+	}{}
+	help, err := configape.Help(&cfg, &configape.Options{Name: "tool", EnvironmentPrefix: "TOOL_", HelpShowNames: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"  --name <Name> (required)        Your name\n                                  env: TOOL_NAME, config: name\n",
+		"  --database-host <Host> (default: localhost)\n                                  env: TOOL_DATABASE_HOST, config: database.host\n",
+		"  --database-port <Port>          env: TOOL_DATABASE_DBPORT, config:\n                                  database.port\n",
+		"Environment and config file only:\n\n  env: TOOL_TOKEN, config: token  API token\n  config: secret\n",
+	}
+	for _, str := range expected {
+		if !strings.Contains(help, str) {
+			t.Errorf("expected the help to contain %q:\n%s", str, help)
+		}
+	}
+
+	// Without the option the help is unchanged
+	help, _ = configape.Help(&cfg, &configape.Options{Name: "tool"})
+	if strings.Contains(help, "env:") || strings.Contains(help, "(required)") || strings.Contains(help, "token") {
+		t.Errorf("expected no names in the help:\n%s", help)
+	}
+}
+
+func TestHelpLayout(t *testing.T) {
+	cfg := struct {
+		Verbose  bool   `short:"v" help:"Log more about what is happening, including every request that is received"`
+		Name     string `required:"true" help:"Your name"`
+		Debug    bool   `hidden:"true"`
+		Listen   string `group:"Networking" default:":8080" help:"Address to listen on"`
+		Database struct {
+			Host string `help:"Database host"`
+		} `group:"Networking"`
+		Files []string `name:"*"`
+	}{}
+	help, err := configape.Help(&cfg, &configape.Options{Name: "tool", HelpWidth: 60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "tool (v0.0.0)\n" +
+		"\n" +
+		"Usage: tool --name <Name> [options] [args...]\n" +
+		"\n" +
+		"  --verbose, -v           Log more about what is happening,\n" +
+		"                          including every request that is\n" +
+		"                          received\n" +
+		"  --name <Name>           Your name\n" +
+		"\n" +
+		"Networking\n" +
+		"\n" +
+		"  --listen <Listen> (default: :8080)\n" +
+		"                          Address to listen on\n" +
+		"  --database-host <Host>  Database host\n"
+	if help != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, help)
+	}
+}
+
+func TestHelpTemplate(t *testing.T) {
+	cfg := struct {
+		Name     string `required:"true" help:"Your name"`
+		Database struct {
+			Host string `default:"localhost" short:"H"`
+		} `group:"Storage"`
+		Serve *struct {
+			Port int `min:"1"`
+		} `cfgtype:"command" help:"Run the server"`
+	}{}
+	tmpl := `{{.Name}}
+{{range .Settings}}{{.Name}}{{range .Settings}}
+  {{.Name}} {{.Flag}} {{.Short}} {{.Value}} {{.Env}} {{.Key}} {{.Type}} {{.Default}} {{.Group}}{{end}}
+{{end}}{{range .Commands}}{{.Path}}: {{.Help}}{{range .Settings}} {{.Flag}} {{.Constraints}}{{end}}
+{{end}}`
+	help, err := configape.Help(&cfg, &configape.Options{Name: "tool", HelpTemplate: tmpl})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "tool\n" +
+		"Name\n" +
+		"database\n" +
+		"  Host --database-host -H <Host> CFG_DATABASE_HOST database.host string localhost Storage\n" +
+		"serve: Run the server --port (min: 1)\n"
+	if help != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, help)
+	}
+
+	// The default template is the normal help
+	help, _ = configape.Help(&cfg, &configape.Options{Name: "tool", HelpTemplate: configape.DefaultHelpTemplate})
+	normal, _ := configape.Help(&cfg, &configape.Options{Name: "tool"})
+	if help != normal {
+		t.Errorf("expected the default template to match the help:\n%s\n%s", help, normal)
+	}
+
+	// Bad templates are errors
+	_, err = configape.Help(&cfg, &configape.Options{HelpTemplate: "{{.Name"})
+	if err == nil {
+		t.Error("expected an error for an unparsable template")
+	}
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+	os.Args = []string{"test", "--help"}
+	err = configape.Apply(&cfg, &configape.Options{HelpTemplate: "{{.Wibble}}", DisableConfigFile: true, HelpWriter: &strings.Builder{}})
+	if err == nil || !strings.Contains(err.Error(), "error rendering help template") {
+		t.Errorf("expected an error rendering the template, got: %v", err)
+	}
+}
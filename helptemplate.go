@@ -0,0 +1,150 @@
+package configape
+
+import (
+	"strings"
+	"text/template"
+)
+
+// DefaultHelpTemplate is the text/template the help is rendered with when Options.HelpTemplate
+// isn't set. It is a good starting point for your own template.
+const DefaultHelpTemplate = `{{.Name}} (v{{.Version}})
+
+{{.Usage}}
+
+{{with .Header}}{{.}}
+
+{{end}}{{with .Command}}{{$.Name}} {{.Path}}
+{{with .Help}}  {{.}}
+{{end}}
+{{end}}{{range $idx, $section := .Sections}}{{if .Heading}}{{if $idx}}
+{{end}}{{.Heading}}
+{{with .Description}}{{$.Wrap . "  "}}{{end}}
+{{end}}{{range .Rows}}{{$.Row .}}{{end}}{{end}}{{with .Footer}}
+{{.}}
+{{end}}`
+
+// HelpModel is what the help template is rendered with. It is read only, changing it has no
+// effect on the settings.
+type HelpModel struct {
+	Name     string
+	Version  string
+	Usage    string         // eg "Usage: tool --name <Name> [options] <command>"
+	Header   string         // Options.HelpHeader
+	Footer   string         // Options.HelpFooter
+	Command  *HelpCommand   // The command selected on the command line, nil if there isn't one
+	Settings []*HelpSetting // The global settings, subsections have their settings in Settings
+	Commands []*HelpCommand // The commands that can be selected, of the selected command if there is one
+	Sections []*HelpSection // The rows of the help in the order the default template shows them
+	Width    int            // The width to wrap the help to
+	Column   int            // The column that the help of each row starts at
+}
+
+// A HelpSection is a heading and its rows, eg a subsection, group or the commands.
+type HelpSection struct {
+	Heading     string // Empty for the first section
+	Description string
+	Rows        []*HelpRow
+}
+
+// A HelpRow is a line of the help, the Usage is in the left column, and each of the Help
+// paragraphs is wrapped in the right column.
+type HelpRow struct {
+	Usage   string       // eg "--port <Port> (default: 80)"
+	Help    []string     // eg the help tag and the environment variable
+	Setting *HelpSetting // The setting of the row, nil for a command
+	Command *HelpCommand // The command of the row, nil for a setting
+}
+
+// A HelpSetting describes a setting. Hidden settings and the remaining arguments are left out.
+type HelpSetting struct {
+	Name        string         // The field name, or the name tag
+	Key         string         // The config file key, eg database.host. Empty if not in the config file
+	Flag        string         // eg --database-host. Empty if not on the command line
+	Short       string         // eg -d. Empty if there isn't one
+	Value       string         // The value placeholder for the flag, eg <Host>. Empty for flags and counters
+	Env         string         // eg CFG_DATABASE_HOST. Empty if not in the environment
+	Type        string         // The Go type, eg int or []string
+	Default     string         // The default value, redacted for secrets
+	Constraints string         // The validation tags, eg (min: 1) (max: 10)
+	Help        string         // The help tag
+	Required    bool           // Set by the required tag
+	Secret      bool           // Set by the secret tag
+	Group       string         // The group tag, or the group of the enclosing subsection
+	Settings    []*HelpSetting // The settings of a subsection, nil otherwise
+
+	setting *cfgSetting
+}
+
+// A HelpCommand describes a command and the settings and commands within it.
+type HelpCommand struct {
+	Name     string // eg up
+	Path     string // eg migrate up
+	Help     string
+	Settings []*HelpSetting
+	Commands []*HelpCommand
+}
+
+// Row renders the row with the help aligned at Column and wrapped to Width. If the usage is
+// too long for the column, then the help starts on the next line.
+func (m *HelpModel) Row(row *HelpRow) string {
+	usage := "  " + row.Usage
+	var lines []string
+	for _, help := range row.Help {
+		lines = append(lines, strings.Split(strings.TrimRight(wrapText(help, m.Width-m.Column, ""), "\n"), "\n")...)
+	}
+	if len(lines) == 0 {
+		return usage + "\n"
+	}
+	result := ""
+	if len(usage)+2 <= m.Column {
+		result += usage + strings.Repeat(" ", m.Column-len(usage)) + lines[0] + "\n"
+		lines = lines[1:]
+	} else {
+		result += usage + "\n"
+	}
+	for _, line := range lines {
+		result += strings.Repeat(" ", m.Column) + line + "\n"
+	}
+	return result
+}
+
+// Wrap word wraps the text to Width, each line starting with the indent. Newlines in the text
+// are kept.
+func (m *HelpModel) Wrap(text string, indent string) string {
+	return wrapText(text, m.Width, indent)
+}
+
+// Parses Options.HelpTemplate, or the default template.
+func (c *cfgApe) parseHelpTemplate() error {
+	text := c.options.HelpTemplate
+	if text == "" {
+		text = DefaultHelpTemplate
+	}
+	var err error
+	c.helpTemplate, err = template.New("help").Parse(text)
+	return err
+}
+
+// Word wraps the text to the width, each line starting with the indent. Newlines in the text
+// are kept.
+func wrapText(text string, width int, indent string) string {
+	if width-len(indent) < 20 {
+		width = len(indent) + 20
+	}
+	result := ""
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := indent
+		for _, word := range strings.Fields(paragraph) {
+			if line != indent && len(line)+1+len(word) > width {
+				result += line + "\n"
+				line = indent
+			}
+			if line != indent {
+				line += " "
+			}
+			line += word
+		}
+		result += line + "\n"
+	}
+	return result
+}
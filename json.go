@@ -1,10 +1,12 @@
 package configape
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"reflect"
+	"strings"
 )
 
 // A function that takes a string, and an offset, and returns the line number
@@ -12,7 +14,7 @@ import (
 func offsetToLineColumn(str string, offset int64) (int, int) {
 	line := 1
 	column := 1
-	for i := int64(0); i < offset; i++ {
+	for i := int64(0); i < offset && i < int64(len(str)); i++ {
 		if str[i] == '\n' {
 			line++
 			column = 1
@@ -23,70 +25,107 @@ func offsetToLineColumn(str string, offset int64) (int, int) {
 	return line, column
 }
 
-func (c *cfgApe) parseJsonConfigFile(cfgFile string, fh io.Reader) error {
-	jsonCfg := make(map[string]json.RawMessage)
-	decoder := json.NewDecoder(fh)
-	err := decoder.Decode(&jsonCfg)
-	if err == nil {
-		err = c.parseJsonMap(c.settings, jsonCfg)
+// The built in json ConfigDecoder.
+func decodeJson(fh io.Reader) (*ConfigNode, error) {
+	data, err := io.ReadAll(fh)
+	if err != nil {
+		return nil, err
 	}
+	root := &ConfigNode{Children: []*ConfigNode{}}
+	err = decodeJsonObject(data, data, 0, root)
 	if err != nil {
-		var offset int
-		var msg string = err.Error()
-		// If the error is of type json.SyntaxError, then we can give a better error message
-		if syntaxError, ok := err.(*json.SyntaxError); ok {
-			offset = int(syntaxError.Offset)
-		}
-		// If the error is of type json.UnmarshalTypeError, then we can give a better error message
-		if typeError, ok := err.(*json.UnmarshalTypeError); ok {
-			offset = int(typeError.Offset)
-			msg = fmt.Sprintf("expected type %s, got %s", typeError.Type, typeError.Value)
-		}
-		if offset > 0 {
-			line, column := offsetToLineColumn(c.options.cfgFileContents, int64(offset))
-			msg = fmt.Sprintf("%s at line %d, column %d", msg, line, column)
+		var syntaxError *json.SyntaxError
+		if errors.As(err, &syntaxError) {
+			// The offset is just after the character that caused the error
+			line, column := offsetToLineColumn(string(data), syntaxError.Offset-1)
+			return nil, &ErrConfigSyntax{Line: line, Column: column, Err: err}
 		}
-		return fmt.Errorf("error parsing config file %s: %s", cfgFile, msg)
+		return nil, err
 	}
-	return nil
+	return root, nil
 }
 
-func (c *cfgApe) parseJsonMap(settings cfgSettings, m map[string]json.RawMessage) error {
-	for key, value := range m {
-		setting := settings.Find(key, "config")
-		if setting == nil {
-			if c.options.AllowUnknownConfigFileKeys {
-				continue
-			}
-			return fmt.Errorf("unknown setting in config file: %s", key)
+// Decode the json object in raw (which starts at offset in the whole file, data) into the
+// children of node.
+func decodeJsonObject(data []byte, raw []byte, offset int64, node *ConfigNode) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		line, column := offsetToLineColumn(string(data), offset)
+		return &ErrConfigSyntax{Line: line, Column: column, Err: errors.New("expected an object")}
+	}
+	for decoder.More() {
+		// The key starts after any whitespace and comma following the previous value
+		keyOffset := decoder.InputOffset()
+		for keyOffset < int64(len(raw)) && strings.ContainsRune(" \t\r\n,", rune(raw[keyOffset])) {
+			keyOffset++
 		}
-		// If we've decided it's a subsection, recurse into it.
-		if setting.fieldType == fieldTypeSubsection {
-			subsection := make(map[string]json.RawMessage)
-			err := json.Unmarshal(value, &subsection)
-			if err != nil {
-				return err
-			}
-			err = c.parseJsonMap(setting.subsection, subsection)
+		token, err = decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return err
+		}
+		start := offset + decoder.InputOffset() - int64(len(value))
+		child := jsonNode(key, value)
+		child.Line, child.Column = offsetToLineColumn(string(data), offset+keyOffset)
+		if len(value) > 0 && value[0] == '{' {
+			child.Children = []*ConfigNode{}
+			err = decodeJsonObject(data, value, start, child)
 			if err != nil {
 				return err
 			}
-			continue
 		}
-		//debugf("key %s is type %s\n", key, setting.reflectType)
-		// Just use json.Unmarshal to unmarshal the value into the reflectType
-		// of the setting
-		// Create a pointer to the setting.reflectType
-		v := reflect.New(setting.reflectType)
-		// Unmarshal the value into the pointer
-		err := json.Unmarshal(value, v.Interface())
-		if err != nil {
-			return err
+		node.Children = append(node.Children, child)
+	}
+	_, err = decoder.Token()
+	return err
+}
+
+// Make a node for the raw json value.
+func jsonNode(key string, raw json.RawMessage) *ConfigNode {
+	node := &ConfigNode{Key: key}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if decoder.Decode(&node.Value) == nil {
+		node.Value = jsonNumbers(node.Value)
+	}
+	node.Decode = func(v interface{}) error {
+		err := json.Unmarshal(raw, v)
+		// If the error is of type json.UnmarshalTypeError, then we can give a better error message
+		var typeError *json.UnmarshalTypeError
+		if errors.As(err, &typeError) {
+			return fmt.Errorf("expected type %s, got %s", typeError.Type, typeError.Value)
 		}
-		//debugf("%s: v is type %s, val %+v\n", key, reflect.TypeOf(v), v.Elem())
-		setting.reflectValue = v.Elem()
-		setting.valueSet = true
+		return err
 	}
+	return node
+}
 
-	return nil
+// Replace the json.Numbers in a decoded value with int64 or float64.
+func jsonNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for idx := range v {
+			v[idx] = jsonNumbers(v[idx])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = jsonNumbers(v[key])
+		}
+	}
+	return value
 }
@@ -45,7 +45,7 @@ func TestJsonParse(t *testing.T) {
 		options:  options,
 	}
 	fh := bytes.NewBufferString(fileContents)
-	err := c.parseJsonConfigFile("fake.json", fh)
+	err := c.decodeConfigFile("fake.json", ConfigDecoderFunc(decodeJson), fh)
 	if err != nil {
 		t.Error(err)
 	}
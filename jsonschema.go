@@ -0,0 +1,195 @@
+package configape
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// A JSON Schema, with just the keywords that can be derived from the settings.
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"` // false, or the schema of the values of a map
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Minimum              interface{}            `json:"minimum,omitempty"`
+	Maximum              interface{}            `json:"maximum,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) for the config file of cfg, so that editors
+// and linters can check config files. It has the type, help, default, and validation tags of
+// each setting, and the required settings without a default. Unknown keys aren't allowed unless
+// Options.AllowUnknownConfigFileKeys is set.
+func JSONSchema(cfg interface{}, options *Options) (string, error) {
+	c := cfgApe{}
+	c.cfg = cfg
+	if options == nil {
+		options = &Options{}
+	}
+	c.options = *options
+
+	err := c.parseStructIntoSettings()
+	if err != nil {
+		return "", err
+	}
+	err = c.settings.SetDefaults()
+	if err != nil {
+		return "", err
+	}
+	schema := c.objectSchema(c.settings)
+	schema.Schema = jsonSchemaDraft
+	schema.Title = c.options.Name
+	schema.Description = c.options.HelpHeader
+	str, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(str) + "\n", nil
+}
+
+// The schema of the settings that can be in the config file. Hidden settings are included, as
+// they can still be set.
+func (c *cfgApe) objectSchema(settings cfgSettings) *jsonSchema {
+	schema := &jsonSchema{Type: "object", Properties: map[string]*jsonSchema{}}
+	if !c.options.AllowUnknownConfigFileKeys {
+		schema.AdditionalProperties = false
+	}
+	for i := 0; i < len(settings); i++ {
+		setting := &settings[i]
+		if setting.isCommand() || setting.name == "*" {
+			continue
+		}
+		if setting.fieldType == fieldTypeSubsection {
+			sub := c.objectSchema(setting.subsection)
+			sub.Description = setting.help
+			schema.Properties[setting.configKey()] = sub
+			// The subsection must be there for its required settings to be
+			if len(sub.Required) > 0 {
+				schema.Required = append(schema.Required, setting.configKey())
+			}
+			continue
+		}
+		schema.Properties[setting.configKey()] = settingSchema(setting)
+		if setting.required && !setting.valueSet {
+			schema.Required = append(schema.Required, setting.configKey())
+		}
+	}
+	return schema
+}
+
+func settingSchema(setting *cfgSetting) *jsonSchema {
+	schema := typeSchema(setting.reflectType)
+	schema.Description = setting.help
+	if setting.valueSet && !setting.secret {
+		schema.Default = setting.textValue()
+	}
+	if setting.layout != "" {
+		// Not RFC 3339, the help should say what it is
+		schema.Format = ""
+	}
+	schema.WriteOnly = setting.secret
+
+	// min, max, oneof and pattern are for each item of a list
+	v := setting.validation
+	item := schema
+	if schema.Items != nil {
+		item = schema.Items
+	}
+	// Durations are strings, so their min and max can't be in the schema
+	if v.min != nil && item.Type != "string" {
+		item.Minimum = v.min.Interface()
+	}
+	if v.max != nil && item.Type != "string" {
+		item.Maximum = v.max.Interface()
+	}
+	for _, option := range v.oneOf {
+		// The options are strings in the tag, but should be the type of the value
+		value, err := strToType(validationElemType(setting.reflectType), option)
+		if err != nil {
+			item.Enum = append(item.Enum, option)
+		} else {
+			item.Enum = append(item.Enum, value.Interface())
+		}
+	}
+	if v.pattern != nil {
+		item.Pattern = v.pattern.String()
+	}
+	if len(v.schemes) > 0 {
+		// Absolute, as they must have one of the schemes
+		item.Format = "uri"
+	}
+
+	// The length tags are the number of items of a list, or the length of a string
+	minLen, maxLen := v.minLen, v.maxLen
+	if v.exactLen != nil {
+		minLen, maxLen = v.exactLen, v.exactLen
+	}
+	if schema.Type == "array" {
+		schema.MinItems, schema.MaxItems = minLen, maxLen
+	} else {
+		schema.MinLength, schema.MaxLength = minLen, maxLen
+	}
+	return schema
+}
+
+// The schema for a Go type. Types with their own unmarshaler could be anything, unless they
+// unmarshal from text.
+func typeSchema(valType reflect.Type) *jsonSchema {
+	for valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	if valType == durationType {
+		return &jsonSchema{Type: "string"}
+	}
+	switch reflect.New(valType).Interface().(type) {
+	case *time.Time:
+		return &jsonSchema{Type: "string", Format: "date-time"}
+	case *url.URL:
+		return &jsonSchema{Type: "string", Format: "uri-reference"}
+	case *regexp.Regexp:
+		return &jsonSchema{Type: "string", Format: "regex"}
+	case json.Unmarshaler, yaml.Unmarshaler:
+		return &jsonSchema{}
+	case encoding.TextUnmarshaler:
+		return &jsonSchema{Type: "string"}
+	}
+	switch valType.Kind() {
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: typeSchema(valType.Elem())}
+	case reflect.Map:
+		return &jsonSchema{Type: "object", AdditionalProperties: typeSchema(valType.Elem())}
+	case reflect.Struct:
+		return &jsonSchema{Type: "object"}
+	}
+	if isNumberKind(valType.Kind()) {
+		return &jsonSchema{Type: "integer"}
+	}
+	// eg interface{}
+	return &jsonSchema{}
+}
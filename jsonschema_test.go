@@ -0,0 +1,73 @@
+package configape
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestJSONSchema(t *testing.T) {
+	cfg := struct {
+		Name     string   `required:"true" help:"Your name"`
+		Port     int      `default:"8080" min:"1" max:"65535"`
+		Level    int      `oneof:"1,2,3"`
+		Mode     string   `oneof:"fast,slow" minlen:"1"`
+		Ratio    float64  `cli:"-"`
+		Tags     []string `default:"a,b" maxlen:"3" pattern:"^[a-z]+$"`
+		Password string   `secret:"true" default:"hunter2"`
+		Debug    bool     `hidden:"true"`
+		Serve    *struct {
+			Port int
+		} `cfgtype:"command"`
+		Database struct {
+			Host string `default:"localhost" required:"true"`
+			User string `required:"true"`
+		} `help:"Database settings"`
+	}{}
+	str, err := JSONSchema(&cfg, &Options{Name: "tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(str), &schema); err != nil {
+		t.Fatalf("invalid json: %s\n%s", err, str)
+	}
+	properties := schema["properties"].(map[string]interface{})
+	expected := map[string]interface{}{
+		"name":     map[string]interface{}{"type": "string", "description": "Your name"},
+		"port":     map[string]interface{}{"type": "integer", "default": 8080.0, "minimum": 1.0, "maximum": 65535.0},
+		"level":    map[string]interface{}{"type": "integer", "enum": []interface{}{1.0, 2.0, 3.0}},
+		"mode":     map[string]interface{}{"type": "string", "enum": []interface{}{"fast", "slow"}, "minLength": 1.0},
+		"ratio":    map[string]interface{}{"type": "number"},
+		"tags":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "pattern": "^[a-z]+$"}, "default": []interface{}{"a", "b"}, "maxItems": 3.0},
+		"password": map[string]interface{}{"type": "string", "writeOnly": true},
+		"debug":    map[string]interface{}{"type": "boolean"},
+		"database": map[string]interface{}{
+			"type":        "object",
+			"description": "Database settings",
+			"properties": map[string]interface{}{
+				"host": map[string]interface{}{"type": "string", "default": "localhost"},
+				"user": map[string]interface{}{"type": "string"},
+			},
+			"required":             []interface{}{"user"},
+			"additionalProperties": false,
+		},
+	}
+	if !reflect.DeepEqual(properties, expected) {
+		t.Errorf("unexpected properties:\n%s", str)
+	}
+	if schema["$schema"] != "https://json-schema.org/draft/2020-12/schema" || schema["title"] != "tool" || schema["additionalProperties"] != false {
+		t.Errorf("unexpected schema:\n%s", str)
+	}
+	if !reflect.DeepEqual(schema["required"], []interface{}{"name", "database"}) {
+		t.Errorf("expected name and database to be required, got %v", schema["required"])
+	}
+
+	// Unknown keys are allowed if they are in the config file
+	str, _ = JSONSchema(&cfg, &Options{AllowUnknownConfigFileKeys: true})
+	schema = nil
+	json.Unmarshal([]byte(str), &schema)
+	if _, ok := schema["additionalProperties"]; ok {
+		t.Errorf("expected additionalProperties to not be set:\n%s", str)
+	}
+}
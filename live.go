@@ -0,0 +1,102 @@
+package configape
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Live holds a configuration that can be reloaded, so that it can be safely shared between
+// goroutines. Pass a *Live[T] to Apply or Watch in place of a *T, and use Load to get the current
+// configuration. Each reload is a new *T, so the one returned by Load never changes and mustn't be
+// changed. Help, Save and the other functions that take a config struct use the current one.
+type Live[T any] struct {
+	value       atomic.Pointer[T]
+	mutex       sync.Mutex
+	subscribers []func(old, new *T)
+	paths       map[string][]func(old, new *T)
+}
+
+// How Apply and Watch use a Live without knowing its type.
+type liveConfig interface {
+	newConfig() interface{}
+	current() interface{}
+	store(cfg interface{}, changed []string)
+}
+
+// Load returns the current configuration, nil if it hasn't been applied yet.
+func (l *Live[T]) Load() *T {
+	return l.value.Load()
+}
+
+// Subscribe registers a function that is called with the old and new configuration whenever it
+// changes.
+func (l *Live[T]) Subscribe(fn func(old, new *T)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.subscribers = append(l.subscribers, fn)
+}
+
+// SubscribePath registers a function that is called with the old and new configuration when the
+// setting with the path (eg log.level) changes. The path of a subsection (eg log) is any of the
+// settings in it.
+func (l *Live[T]) SubscribePath(path string, fn func(old, new *T)) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.paths == nil {
+		l.paths = map[string][]func(old, new *T){}
+	}
+	l.paths[path] = append(l.paths[path], fn)
+}
+
+func (l *Live[T]) newConfig() interface{} {
+	return new(T)
+}
+
+func (l *Live[T]) current() interface{} {
+	// A nil *T isn't a nil interface{}
+	if cfg := l.value.Load(); cfg != nil {
+		return cfg
+	}
+	return nil
+}
+
+// Replaces the configuration and tells the subscribers what changed. The first configuration
+// isn't a change.
+func (l *Live[T]) store(cfg interface{}, changed []string) {
+	newCfg := cfg.(*T)
+	oldCfg := l.value.Swap(newCfg)
+	if oldCfg == nil || len(changed) == 0 {
+		return
+	}
+	l.mutex.Lock()
+	fns := append([]func(old, new *T){}, l.subscribers...)
+	for path, pathFns := range l.paths {
+		for _, setting := range changed {
+			if setting == path || strings.HasPrefix(setting, path+".") {
+				fns = append(fns, pathFns...)
+				break
+			}
+		}
+	}
+	l.mutex.Unlock()
+	for _, fn := range fns {
+		fn(oldCfg, newCfg)
+	}
+}
+
+// Apply the configuration to a new struct, which replaces the current one if there are no errors.
+func (c *cfgApe) applyLive(live liveConfig, options *Options) error {
+	cfg := live.newConfig()
+	err := c.Apply(cfg, options)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	if old := live.current(); old != nil {
+		changed = changedSettings(c.settings, reflect.ValueOf(old).Elem(), reflect.ValueOf(cfg).Elem())
+	}
+	live.store(cfg, changed)
+	return nil
+}
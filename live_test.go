@@ -0,0 +1,134 @@
+package configape
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type liveTestConfig struct {
+	Log struct {
+		Level string `default:"info"`
+	}
+	Port int
+}
+
+func TestLive(t *testing.T) {
+	live := &Live[liveTestConfig]{}
+	if live.Load() != nil {
+		t.Error("expected nil before Apply")
+	}
+	err := Apply(live, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "--port", "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := live.Load()
+	if first == nil || first.Port != 1 || first.Log.Level != "info" {
+		t.Fatalf("expected the config, got %+v", first)
+	}
+
+	var all, level, log, port int
+	live.Subscribe(func(old, new *liveTestConfig) {
+		all++
+		if old != first || new.Log.Level != "debug" {
+			t.Errorf("expected the old and new config, got %+v %+v", old, new)
+		}
+	})
+	live.SubscribePath("log.level", func(old, new *liveTestConfig) { level++ })
+	live.SubscribePath("log", func(old, new *liveTestConfig) { log++ })
+	live.SubscribePath("port", func(old, new *liveTestConfig) { port++ })
+
+	// Applying again replaces it, and tells the subscribers
+	err = Apply(live, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "--port", "1", "--log-level", "debug"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all != 1 || level != 1 || log != 1 || port != 0 {
+		t.Errorf("expected the log subscribers to be called once, got %d %d %d %d", all, level, log, port)
+	}
+	if first.Log.Level != "info" || live.Load().Log.Level != "debug" {
+		t.Error("expected the old config to be unchanged")
+	}
+
+	// An invalid config doesn't replace it
+	current := live.Load()
+	err = Apply(live, &Options{DisableConfigFile: true, DisableHelpOnMissingRequired: true, osArgs: []string{"cfgape", "--port", "x"}})
+	if err == nil || live.Load() != current {
+		t.Errorf("expected an error and the config to stay, got %v", err)
+	}
+}
+
+func TestLiveWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	os.WriteFile(path, []byte(`{"port": 1}`), 0644)
+	live := &Live[liveTestConfig]{}
+	w, err := Watch(live, &Options{ConfigFilename: path, WatchInterval: 10 * time.Millisecond, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if live.Load().Port != 1 || w.Current() != live.Load() {
+		t.Fatalf("expected port 1, got %+v", live.Load())
+	}
+	changed := make(chan *liveTestConfig, 1)
+	live.SubscribePath("port", func(old, new *liveTestConfig) { changed <- new })
+
+	// Readers can load it while it is being reloaded
+	stop := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				_ = live.Load().Port
+			}
+		}
+	}()
+	os.WriteFile(path, []byte(`{"port": 22}`), 0644)
+	select {
+	case cfg := <-changed:
+		if cfg.Port != 22 || live.Load() != cfg {
+			t.Errorf("expected port 22, got %+v", cfg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the change")
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestLiveOutput(t *testing.T) {
+	live := &Live[liveTestConfig]{}
+	// Before it is applied, the settings are still there
+	help, err := Help(live, &Options{Name: "cfgape"})
+	if err != nil || !strings.Contains(help, "--log-level") {
+		t.Errorf("expected the help for the settings, got %v %s", err, help)
+	}
+	err = Apply(live, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "--port", "1234"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := Save(live, path, nil); err != nil {
+		t.Fatal(err)
+	}
+	contents, _ := os.ReadFile(path)
+	if !strings.Contains(string(contents), `"port": 1234`) {
+		t.Errorf("expected the current config to be saved, got %s", contents)
+	}
+	sample, err := GenerateConfigFile(live, "yaml", nil)
+	if err != nil || !strings.Contains(sample, "level") {
+		t.Errorf("expected a sample config, got %v %s", err, sample)
+	}
+	schema, err := JSONSchema(live, nil)
+	if err != nil || !strings.Contains(schema, `"port"`) {
+		t.Errorf("expected a schema, got %v %s", err, schema)
+	}
+}
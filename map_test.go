@@ -0,0 +1,104 @@
+package configape
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mapConfig struct {
+	Labels  map[string]string `short:"l"`
+	Weights map[string]int    `default:"a=1,b=2"`
+	Limits  map[string]float64
+}
+
+func TestMapSettings(t *testing.T) {
+	os.Setenv("CFG_LABELS", "a=1,b='x,y'")
+	defer os.Unsetenv("CFG_LABELS")
+
+	cfg := mapConfig{}
+	err := Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]string{"a": "1", "b": "x,y"}) {
+		t.Errorf("expected the labels from the environment, got %v", cfg.Labels)
+	}
+	if !reflect.DeepEqual(cfg.Weights, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("expected the default weights, got %v", cfg.Weights)
+	}
+
+	// The command line adds to the map
+	cfg = mapConfig{}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "--labels", "c=3", "-l", "d=4,e=5", "--weights=b=20", "--limits", "cpu=0.5"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]string{"a": "1", "b": "x,y", "c": "3", "d": "4", "e": "5"}) {
+		t.Errorf("expected the labels to accumulate, got %v", cfg.Labels)
+	}
+	if !reflect.DeepEqual(cfg.Weights, map[string]int{"a": 1, "b": 20}) || cfg.Limits["cpu"] != 0.5 {
+		t.Errorf("expected the weights and limits to be set, got %v %v", cfg.Weights, cfg.Limits)
+	}
+
+	// Errors
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--weights", "c=x", "--labels", "nokey"}})
+	var parseErr *ErrParseValue
+	if !errors.As(err, &parseErr) || parseErr.Setting != "weights" || !strings.Contains(err.Error(), "expected key=value, got nokey") {
+		t.Errorf("expected parse errors, got: %v", err)
+	}
+
+	_, err = structToSettings(reflect.TypeOf(struct{ Bad map[int]string }{}))
+	if err == nil {
+		t.Error("expected an error for a map without string keys")
+	}
+}
+
+func TestMapConfigFiles(t *testing.T) {
+	files := map[string]string{
+		"json": `{"labels": {"a": "1", "b": "2"}, "weights": {"c": 3}}`,
+		"yaml": "labels:\n  a: \"1\"\n  b: \"2\"\nweights:\n  c: 3\n",
+		"toml": "weights = { c = 3 }\n[labels]\na = \"1\"\nb = \"2\"\n",
+	}
+	for format, contents := range files {
+		cfg := mapConfig{}
+		err := Apply(&cfg, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if !reflect.DeepEqual(cfg.Labels, map[string]string{"a": "1", "b": "2"}) || !reflect.DeepEqual(cfg.Weights, map[string]int{"c": 3}) {
+			t.Errorf("%s: expected the maps from the file, got %v %v", format, cfg.Labels, cfg.Weights)
+		}
+	}
+
+	// And they can be saved and read back
+	dir := t.TempDir()
+	cfg := mapConfig{Labels: map[string]string{"a": "x,y", "b": "2"}, Weights: map[string]int{}}
+	for _, format := range []string{"json", "yaml", "toml"} {
+		path := filepath.Join(dir, "config."+format)
+		if err := Save(&cfg, path, nil); err != nil {
+			t.Fatal(err)
+		}
+		loaded := mapConfig{}
+		err := Apply(&loaded, &Options{ConfigFilename: path, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if !reflect.DeepEqual(loaded.Labels, cfg.Labels) || len(loaded.Weights) != 0 {
+			t.Errorf("%s: expected %v, got %v %v", format, cfg.Labels, loaded.Labels, loaded.Weights)
+		}
+	}
+}
+
+func TestMapHelp(t *testing.T) {
+	help, err := Help(&mapConfig{}, &Options{Name: "tool"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(help, "--labels <key=value>, -l <key=value>") || !strings.Contains(help, "--weights <key=value> (default: a=1,b=2)") {
+		t.Errorf("expected the maps as <key=value>:\n%s", help)
+	}
+}
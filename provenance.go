@@ -0,0 +1,166 @@
+package configape
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"text/tabwriter"
+)
+
+// SourceKind is the kind of place a setting's value came from.
+type SourceKind int
+
+const (
+	SourceNone        SourceKind = iota // The setting was not set
+	SourceDefault                       // The default tag
+	SourceFile                          // A config file
+	SourceEnvironment                   // An environment variable
+	SourceCommandLine                   // A command line argument
+)
+
+func (k SourceKind) String() string {
+	switch k {
+	case SourceDefault:
+		return "default"
+	case SourceFile:
+		return "file"
+	case SourceEnvironment:
+		return "environment"
+	case SourceCommandLine:
+		return "command line"
+	default:
+		return "unset"
+	}
+}
+
+// Source describes where a setting's value came from.
+type Source struct {
+	Kind   SourceKind
+	Name   string // The config file path, environment variable name, or command line argument
+	Line   int    // For config files, the line the value was on (if known)
+	Column int    // For config files, the column the value was at (if known)
+}
+
+func (s Source) String() string {
+	switch s.Kind {
+	case SourceDefault:
+		return "default value"
+	case SourceFile:
+		if s.Line > 0 {
+			return fmt.Sprintf("file %s:%d", s.Name, s.Line)
+		}
+		return fmt.Sprintf("file %s", s.Name)
+	case SourceEnvironment:
+		return fmt.Sprintf("environment %s", s.Name)
+	case SourceCommandLine:
+		return fmt.Sprintf("command line %s", s.Name)
+	default:
+		return "unset"
+	}
+}
+
+// Provenance is the final value of a setting, and where that value came from.
+type Provenance struct {
+	Path   string // The config file key of the setting, with subsections separated by dots (eg database.host)
+	Value  string // The final value of the setting
+	Source Source // Where the value came from
+}
+
+func (p Provenance) String() string {
+	return fmt.Sprintf("%s = %s (%s)", p.Path, p.Value, p.Source)
+}
+
+// Explain applies the configuration to cfg exactly as Apply does, and then returns every setting
+// with its final value and where that value came from.
+func Explain(cfg interface{}, options *Options) ([]Provenance, error) {
+	c := cfgApe{}
+	err := c.Apply(cfg, options)
+	if err != nil {
+		return nil, err
+	}
+	return c.explain(), nil
+}
+
+// Returns the provenance of all the settings, using the values in the cfg struct.
+func (c *cfgApe) explain() []Provenance {
+	value := reflect.ValueOf(c.cfg)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	return explainSettings(value, c.settings, "")
+}
+
+func explainSettings(cfgValue reflect.Value, settings cfgSettings, prefix string) []Provenance {
+	var result []Provenance
+	for _, setting := range settings {
+		path := prefix + setting.configKey()
+		value := cfgValue.Field(setting.idx)
+		if setting.fieldType == fieldTypeSubsection {
+			result = append(result, explainSettings(value, setting.subsection, path+".")...)
+			continue
+		}
+		if setting.fieldType == fieldTypeCommand {
+			if setting.valueSet {
+				path = prefix + setting.commandName()
+				result = append(result, explainSettings(reflect.Indirect(value), setting.subsection, path+".")...)
+			}
+			continue
+		}
+		formatted := formatValue(value)
+		if setting.layout != "" {
+			formatted = fmt.Sprint(textValue(value, setting.layout))
+		}
+		if setting.secret && !value.IsZero() {
+			formatted = redacted
+		}
+		result = append(result, Provenance{
+			Path:   path,
+			Value:  formatted,
+			Source: setting.whereSet,
+		})
+	}
+	return result
+}
+
+// Format a value for displaying to a user
+func formatValue(value reflect.Value) string {
+	if !value.IsValid() {
+		return ""
+	}
+	if value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return "<nil>"
+		}
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.String {
+		return strconv.Quote(value.String())
+	}
+	// Use the String method if there is one on the pointer (eg for custom types)
+	if value.CanAddr() {
+		if stringer, ok := value.Addr().Interface().(fmt.Stringer); ok {
+			return stringer.String()
+		}
+	}
+	return fmt.Sprintf("%v", value.Interface())
+}
+
+// Print every setting, its value and where it came from.
+func (c *cfgApe) printConfig() {
+	var fh io.Writer
+	fh = os.Stderr
+	if c.options.HelpWriter != nil {
+		fh = c.options.HelpWriter
+	}
+	writeProvenance(fh, c.explain())
+}
+
+func writeProvenance(fh io.Writer, provenance []Provenance) {
+	w := tabwriter.NewWriter(fh, 0, 4, 2, ' ', 0)
+	for _, p := range provenance {
+		fmt.Fprintf(w, "%s\t%s\t(%s)\n", p.Path, p.Value, p.Source)
+	}
+	w.Flush()
+}
@@ -0,0 +1,83 @@
+package configape
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	cfg := struct {
+		Default  string `default:"baz"`
+		File     string
+		Env      string
+		CmdLine  int
+		Unset    string
+		Database struct {
+			Host string
+		}
+	}{}
+	fileContents := "{\n\t\"file\": \"fileset\",\n\t\"database\": {\n\t\t\"host\": \"dbhost\"\n\t}\n}"
+	os.Setenv("CFG_ENV", "envset")
+	defer os.Unsetenv("CFG_ENV")
+	provenance, err := Explain(&cfg, &Options{
+		ConfigFilename:  "test.json",
+		cfgFileContents: fileContents,
+		osArgs:          []string{"cfgape", "--cmd-line=42"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Provenance{
+		{Path: "default", Value: `"baz"`, Source: Source{Kind: SourceDefault}},
+		{Path: "file", Value: `"fileset"`, Source: Source{Kind: SourceFile, Name: "test.json", Line: 2, Column: 2}},
+		{Path: "env", Value: `"envset"`, Source: Source{Kind: SourceEnvironment, Name: "CFG_ENV"}},
+		{Path: "cmd_line", Value: "42", Source: Source{Kind: SourceCommandLine, Name: "--cmd-line"}},
+		{Path: "unset", Value: `""`, Source: Source{}},
+		{Path: "database.host", Value: `"dbhost"`, Source: Source{Kind: SourceFile, Name: "test.json", Line: 4, Column: 3}},
+	}
+	if len(provenance) != len(expected) {
+		t.Fatalf("expected %d settings, got %d: %v", len(expected), len(provenance), provenance)
+	}
+	for idx := range expected {
+		if provenance[idx] != expected[idx] {
+			t.Errorf("expected %s, got %s", expected[idx], provenance[idx])
+		}
+	}
+	if provenance[5].String() != `database.host = "dbhost" (file test.json:4)` {
+		t.Errorf("unexpected string: %s", provenance[5])
+	}
+}
+
+func TestPrintConfig(t *testing.T) {
+	cfg := struct {
+		Foo string `default:"baz"`
+		Bar int
+	}{}
+	buffer := bytes.NewBuffer([]byte{})
+	err := Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		EnablePrintConfig:  true,
+		HelpWriter:         buffer,
+		osArgs:             []string{"cfgape", "--print-config", "--bar", "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "foo  \"baz\"  (default value)\nbar  3      (command line --bar)\n"
+	if buffer.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buffer.String())
+	}
+
+	// Without the option it's an unknown argument
+	err = Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--print-config"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "--print-config") {
+		t.Errorf("expected unknown argument error: %v", err)
+	}
+}
@@ -5,15 +5,39 @@ package configape
 
 import (
 	"encoding"
+	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
+)
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
 )
 
 // Takes a reflect.Value and a string, and assigns the string to the value, using sensible
 // conversions (eg "true" to true, "1" to 1, etc)
 func strToValue(value reflect.Value, str string) error {
+	// The types that are parsed here rather than by their own unmarshalers (eg url.URL)
+	if value.Kind() == reflect.Ptr {
+		if parse, ok := textParsers[value.Type().Elem()]; ok {
+			// Empty is how the zero value is written out, eg an IP address that isn't set
+			if str == "" {
+				value.Elem().Set(reflect.Zero(value.Type().Elem()))
+				return nil
+			}
+			parsed, err := parse(str)
+			if err != nil {
+				return err
+			}
+			value.Elem().Set(reflect.ValueOf(parsed))
+			return nil
+		}
+	}
 	// If the type implements TextUnmarshaler, then we can use that
 	if unmarshal, ok := value.Interface().(encoding.TextUnmarshaler); ok {
 		err := unmarshal.UnmarshalText([]byte(str))
@@ -26,6 +50,15 @@ func strToValue(value reflect.Value, str string) error {
 	if value.Kind() == reflect.Ptr {
 		value = value.Elem()
 	}
+	// Durations are int64s, but are written as eg 1m30s
+	if value.Type() == durationType {
+		d, err := time.ParseDuration(str)
+		if err != nil {
+			return fmt.Errorf("invalid duration value: %s", str)
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
 
 	switch value.Kind() {
 	case reflect.Interface:
@@ -39,23 +72,27 @@ func strToValue(value reflect.Value, str string) error {
 			return err
 		}
 		value.Set(slice)
+	case reflect.Map:
+		// A comma separated list of key=value, the values can be quoted
+		m, err := strMapToType(value.Type(), stringListToMap(str))
+		if err != nil {
+			return err
+		}
+		value.Set(m)
 	case reflect.String:
 		value.SetString(str)
-	case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64:
-		// Because json and yaml unmarshal numbers as float64, we need to handle that here
-		f, err := strconv.ParseFloat(str, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := parseInt(str, value.Type())
 		if err != nil {
-			return fmt.Errorf("invalid integer value: %s", str)
+			return err
 		}
-		value.SetInt(int64(f))
-
+		value.SetInt(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		// Because json and yaml unmarshal numbers as float64, we need to handle that here
-		f, err := strconv.ParseFloat(str, 64)
+		u, err := parseUint(str, value.Type())
 		if err != nil {
-			return fmt.Errorf("invalid integer value: %s", str)
+			return err
 		}
-		value.SetUint(uint64(f))
+		value.SetUint(u)
 	case reflect.Bool:
 		switch strings.ToLower(str) {
 		case "true", "t", "1":
@@ -79,6 +116,71 @@ func strToValue(value reflect.Value, str string) error {
 	return nil
 }
 
+// Parses an integer of the type exactly, so it's an error if it isn't whole or doesn't fit rather
+// than being truncated. The 0x, 0o and 0b prefixes and underscores (eg 1_000_000) are allowed.
+func parseInt(str string, valType reflect.Type) (int64, error) {
+	i, err := strconv.ParseInt(intLiteral(str), 0, valType.Bits())
+	if err != nil {
+		return 0, intError(str, valType, err)
+	}
+	return i, nil
+}
+
+func parseUint(str string, valType reflect.Type) (uint64, error) {
+	literal := intLiteral(str)
+	if strings.HasPrefix(literal, "-") {
+		return 0, intError(str, valType, strconv.ErrRange)
+	}
+	u, err := strconv.ParseUint(literal, 0, valType.Bits())
+	if err != nil {
+		return 0, intError(str, valType, err)
+	}
+	return u, nil
+}
+
+// Is the type (or what it points to) an integer, and not a duration
+func isIntegerType(valType reflect.Type) bool {
+	if valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	return valType != durationType && isNumberKind(valType.Kind()) && valType.Kind() != reflect.Float32 && valType.Kind() != reflect.Float64
+}
+
+// Go treats a leading zero as octal, which isn't what anyone means by --day 08, so leading zeros
+// are removed unless there is a prefix.
+func intLiteral(str string) string {
+	str = strings.TrimSpace(str)
+	sign := ""
+	if strings.HasPrefix(str, "-") || strings.HasPrefix(str, "+") {
+		sign, str = str[:1], str[1:]
+	}
+	if sign == "+" {
+		sign = ""
+	}
+	lower := strings.ToLower(str)
+	if !strings.HasPrefix(lower, "0x") && !strings.HasPrefix(lower, "0o") && !strings.HasPrefix(lower, "0b") {
+		if strings.HasPrefix(str, "0") {
+			// Only the zeros, strconv checks the underscores are between digits
+			str = strings.TrimLeft(str, "0")
+			if str == "" {
+				str = "0"
+			}
+		}
+	}
+	return sign + str
+}
+
+func intError(str string, valType reflect.Type, err error) error {
+	if !errors.Is(err, strconv.ErrRange) {
+		return fmt.Errorf("invalid integer value: %s", str)
+	}
+	bits := valType.Bits()
+	if valType.Kind() >= reflect.Uint && valType.Kind() <= reflect.Uint64 {
+		return fmt.Errorf("%s is out of range for %s (0 to %d)", str, valType, uint64(math.MaxUint64)>>(64-bits))
+	}
+	return fmt.Errorf("%s is out of range for %s (%d to %d)", str, valType, int64(-1)<<(bits-1), int64(math.MaxInt64)>>(64-bits))
+}
+
 // Takes a reflection type, and a string list, and returns a list of that type,
 // containing the strings converted to that type
 func strListToType(valType reflect.Type, list []string) (reflect.Value, error) {
@@ -115,8 +217,12 @@ func appendStrToListType(valType reflect.Type, currentList reflect.Value, value
 	if currentList.Kind() == reflect.Invalid {
 		// Create a slice of the correct type
 		slice := reflect.MakeSlice(elemType, 1, 1)
-		err := strToValue(slice.Index(0), value)
-		return slice, err
+		elem, err := strToType(elemType.Elem(), value)
+		if err != nil {
+			return reflect.Zero(valType), err
+		}
+		slice.Index(0).Set(elem)
+		return slice, nil
 	}
 	if currentList.Kind() != reflect.Slice {
 		return reflect.Zero(valType), fmt.Errorf("list type is not a slice: %s", currentList.Kind())
@@ -129,13 +235,52 @@ func appendStrToListType(valType reflect.Type, currentList reflect.Value, value
 	for idx := 0; idx < currentList.Len(); idx++ {
 		slice.Index(idx).Set(currentList.Index(idx))
 	}
-	err := strToValue(slice.Index(currentList.Len()), value)
+	elem, err := strToType(elemType.Elem(), value)
 	if err != nil {
 		return reflect.Zero(valType), err
 	}
+	slice.Index(currentList.Len()).Set(elem)
 	return slice, nil
 }
 
+// Takes a map type, and a map of strings, and returns a map of that type containing the strings
+// converted to the value type.
+func strMapToType(mapType reflect.Type, m map[string]string) (reflect.Value, error) {
+	result := reflect.MakeMapWithSize(mapType, len(m))
+	for key, str := range m {
+		value, err := strToType(mapType.Elem(), str)
+		if err != nil {
+			return reflect.Zero(mapType), fmt.Errorf("invalid value for %s: %w", key, err)
+		}
+		result.SetMapIndex(reflect.ValueOf(key).Convert(mapType.Key()), value)
+	}
+	return result, nil
+}
+
+// Adds the key=value pairs in the string to a copy of currentMap, whose type is valType. If
+// currentMap doesn't exist, it creates it.
+func addStrToMapType(valType reflect.Type, currentMap reflect.Value, str string) (reflect.Value, error) {
+	if !strings.Contains(str, "=") {
+		return reflect.Zero(valType), fmt.Errorf("expected key=value, got %s", str)
+	}
+	added, err := strMapToType(valType, stringListToMap(str))
+	if err != nil {
+		return reflect.Zero(valType), err
+	}
+	result := reflect.MakeMap(valType)
+	if currentMap.IsValid() && currentMap.Kind() == reflect.Map {
+		iter := currentMap.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+	iter := added.MapRange()
+	for iter.Next() {
+		result.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return result, nil
+}
+
 // Increment the value provided by the amount provided, starting from zero if the value is an invalid type.
 func incrementNumber(valType reflect.Type, currentValue reflect.Value, amount float64) (reflect.Value, error) {
 	if currentValue.Kind() == reflect.Ptr {
@@ -157,6 +302,10 @@ func incrementNumber(valType reflect.Type, currentValue reflect.Value, amount fl
 
 // Old  method, takes a type not a value
 func strToType(valType reflect.Type, str string) (reflect.Value, error) {
+	// A pointer to one of the textParsers types is nil when empty, as that's how nil is written out
+	if str == "" && valType.Kind() == reflect.Ptr && isParsedType(valType) {
+		return reflect.Zero(valType), nil
+	}
 
 	elemType := valType
 	if valType.Kind() == reflect.Pointer {
@@ -175,6 +324,27 @@ func strToType(valType reflect.Type, str string) (reflect.Value, error) {
 	}
 }
 
+// Converts the string to the type of the setting. Times are parsed with the layout tag if there is
+// one, otherwise they are RFC 3339.
+func (s *cfgSetting) parseValue(str string) (reflect.Value, error) {
+	if s.layout == "" {
+		return strToType(s.reflectType, str)
+	}
+	t, err := time.Parse(s.layout, str)
+	if err != nil {
+		return reflect.Zero(s.reflectType), fmt.Errorf("invalid time value: %s (expected %s)", str, s.layout)
+	}
+	return timeToType(s.reflectType, t), nil
+}
+
+// Returns the time as a time.Time or *time.Time
+func timeToType(valType reflect.Type, t time.Time) reflect.Value {
+	if valType.Kind() == reflect.Ptr {
+		return reflect.ValueOf(&t)
+	}
+	return reflect.ValueOf(t)
+}
+
 // Set the values of the provided struct, based on the values in the settings
 func setValues(cfg interface{}, settings cfgSettings) error {
 	// Loop through the settings and set the values in the cfg struct
@@ -201,6 +371,23 @@ func setValues(cfg interface{}, settings cfgSettings) error {
 			}
 			continue
 		}
+		// Commands are only filled in if they were selected, pointers are allocated then.
+		if setting.fieldType == fieldTypeCommand {
+			if !setting.valueSet {
+				continue
+			}
+			if value.Kind() == reflect.Ptr {
+				if value.IsNil() {
+					value.Set(reflect.New(value.Type().Elem()))
+				}
+				value = value.Elem()
+			}
+			err := setValues(value.Addr().Interface(), setting.subsection)
+			if err != nil {
+				return err
+			}
+			continue
+		}
 		// If there is a reflectValue set (valueSet) then just use that.
 		if setting.valueSet {
 			//debugf("field %s is set to %v\n", setting.name, setting.reflectValue)
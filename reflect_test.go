@@ -3,6 +3,7 @@ package configape
 import (
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -162,3 +163,83 @@ func TestTypes(t *testing.T) {
 	}
 
 }
+
+func TestIntegers(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		str      string
+		expected interface{}
+	}{
+		{int8(0), "-128", int8(-128)},
+		{int64(0), "9007199254740993", int64(9007199254740993)},
+		{uint64(0), "18446744073709551615", uint64(18446744073709551615)},
+		{int(0), "0x1F", int(31)},
+		{int(0), "0o755", int(0755)},
+		{int(0), "0b101", int(5)},
+		{int(0), "1_000_000", int(1000000)},
+		{int(0), "08", int(8)},
+		{int(0), "-010", int(-10)},
+		{int(0), "+7", int(7)},
+		{uint16(0), "0", uint16(0)},
+	}
+	for _, test := range tests {
+		value, err := strToType(reflect.TypeOf(test.value), test.str)
+		if err != nil {
+			t.Errorf("%s: %s", test.str, err)
+			continue
+		}
+		if value.Interface() != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.str, test.expected, value.Interface())
+		}
+	}
+
+	errs := []struct {
+		value    interface{}
+		str      string
+		expected string
+	}{
+		{int(0), "1.9", "invalid integer value: 1.9"},
+		{int(0), "1e3", "invalid integer value: 1e3"},
+		{int(0), "0_", "invalid integer value: 0_"},
+		{int(0), "00_1", "invalid integer value: 00_1"},
+		{int(0), "1__0", "invalid integer value: 1__0"},
+		{int8(0), "128", "128 is out of range for int8 (-128 to 127)"},
+		{uint16(0), "70000", "70000 is out of range for uint16 (0 to 65535)"},
+		{uint(0), "-1", "-1 is out of range for uint (0 to 18446744073709551615)"},
+		{int64(0), "9223372036854775808", "9223372036854775808 is out of range for int64 (-9223372036854775808 to 9223372036854775807)"},
+	}
+	for _, test := range errs {
+		_, err := strToType(reflect.TypeOf(test.value), test.str)
+		if err == nil || err.Error() != test.expected {
+			t.Errorf("%s: expected the error %q, got %v", test.str, test.expected, err)
+		}
+	}
+
+	// The setting is named in the error, and config files are checked too
+	cfg := struct {
+		Port uint16
+	}{}
+	err := Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--port", "70000"}})
+	if err == nil || err.Error() != "failed to parse --port=70000 into cfg.Port: 70000 is out of range for uint16 (0 to 65535)" {
+		t.Errorf("expected an out of range error, got: %v", err)
+	}
+	for format, contents := range map[string]string{"json": `{"port": 1.9}`, "yaml": "port: 1.9\n", "toml": "port = 1.9\n"} {
+		err = Apply(&cfg, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err == nil || !strings.Contains(err.Error(), "invalid integer value: 1.9") {
+			t.Errorf("%s: expected an integer error, got: %v", format, err)
+		}
+	}
+	for format, contents := range map[string]string{"json": `{"port": 70000}`, "yaml": "port: 70000\n", "toml": "port = 70000\n"} {
+		err = Apply(&cfg, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err == nil || !strings.Contains(err.Error(), "70000 is out of range for uint16") {
+			t.Errorf("%s: expected an out of range error, got: %v", format, err)
+		}
+	}
+	big := struct {
+		ID uint64
+	}{}
+	err = Apply(&big, &Options{ConfigFilename: "test.json", cfgFileContents: `{"id": 18446744073709551615}`, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err != nil || big.ID != 18446744073709551615 {
+		t.Errorf("expected the id to be exact, got %d: %v", big.ID, err)
+	}
+}
@@ -0,0 +1,398 @@
+package configape
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The placeholder for required settings in JSON, which can't have comments.
+const requiredPlaceholder = "<required>"
+
+// GenerateConfigFile returns a sample config file for cfg in the format (json, yaml or toml), with
+// every setting that can be set in a config file and its default value. Subsections are nested
+// objects, and in YAML and TOML the help is included as comments and required settings are
+// commented out to be filled in. JSON can't have comments, so required settings are "<required>".
+func GenerateConfigFile(cfg interface{}, format string, options *Options) (string, error) {
+	c := cfgApe{}
+	c.cfg = cfg
+	if options == nil {
+		options = &Options{}
+	}
+	c.options = *options
+
+	err := c.parseStructIntoSettings()
+	if err != nil {
+		return "", err
+	}
+	err = c.settings.SetDefaults()
+	if err != nil {
+		return "", err
+	}
+	return c.makeConfigFile(format)
+}
+
+func (c *cfgApe) makeConfigFile(format string) (string, error) {
+	w := &configWriter{sample: true}
+	return w.write(c.settings, format)
+}
+
+// Prints the sample config file for --generate-config. The format is that of the config file if
+// not given.
+func (c *cfgApe) printConfigFile(format string) error {
+	if format == "" {
+		format = c.configFileType(c.configFileChain("")[0])
+	}
+	// By now the settings have the values from the config files and environment, so start again
+	// with just the defaults
+	str, err := GenerateConfigFile(c.cfg, format, &c.options)
+	if err != nil {
+		return err
+	}
+	// Like the completion script, this is normally redirected to a file
+	fh := c.options.HelpWriter
+	if fh == nil {
+		fh = os.Stdout
+	}
+	fmt.Fprint(fh, str)
+	return nil
+}
+
+// Writes the settings as a config file. A sample has the help as comments, the required settings
+// to be filled in, and no secrets. Otherwise it is the current values of the settings, which is
+// used by Save.
+type configWriter struct {
+	sample       bool
+	skipDefaults bool // Leave out the settings that are the same as their default
+	omitSecrets  bool // Leave out the secret settings
+}
+
+func (w *configWriter) write(settings cfgSettings, format string) (string, error) {
+	var b strings.Builder
+	var err error
+	switch strings.ToLower(format) {
+	case "json":
+		err = w.writeJson(&b, settings, "")
+	case "yaml", "yml":
+		err = w.writeYaml(&b, settings, "")
+	case "toml":
+		err = w.writeToml(&b, settings, "")
+	default:
+		return "", fmt.Errorf("unknown format for config file: %s (use json, yaml or toml)", format)
+	}
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// The settings to write, which aren't commands, the config file setting or the remaining
+// arguments. Samples leave out the hidden settings, and saving leaves out those without a value
+// (including nil ones).
+func (w *configWriter) settings(settings cfgSettings) []*cfgSetting {
+	var result []*cfgSetting
+	for i := 0; i < len(settings); i++ {
+		setting := &settings[i]
+		if setting.isCommand() || setting.fieldType == fieldTypeConfigFile || setting.name == "*" {
+			continue
+		}
+		if (w.sample && setting.hidden) || (w.omitSecrets && setting.secret) {
+			continue
+		}
+		if setting.fieldType == fieldTypeSubsection {
+			if len(w.settings(setting.subsection)) == 0 {
+				continue
+			}
+		} else if !w.sample && (!setting.valueSet || w.isNil(setting) || (w.skipDefaults && setting.isDefault())) {
+			continue
+		}
+		result = append(result, setting)
+	}
+	return result
+}
+
+// Is the value of the setting the same as its default (or the zero value if it has none)
+func (s *cfgSetting) isDefault() bool {
+	defaultValue := reflect.Zero(s.reflectType)
+	if s.defaultValue != "" {
+		var err error
+		defaultValue, err = s.parseValue(s.defaultValue)
+		if err != nil {
+			return false
+		}
+	}
+	return reflect.DeepEqual(s.reflectValue.Interface(), defaultValue.Interface())
+}
+
+// Is the setting a required one that a sample leaves for the user to fill in
+func (w *configWriter) placeholder(setting *cfgSetting) bool {
+	return w.sample && setting.required && !setting.valueSet
+}
+
+// Is the value to be written nil, eg an interface{} that isn't set. TOML has no null, so samples
+// have it commented out.
+func (w *configWriter) isNil(setting *cfgSetting) bool {
+	if !setting.valueSet || (w.sample && setting.secret) {
+		valType := setting.reflectType
+		for valType.Kind() == reflect.Ptr {
+			valType = valType.Elem()
+		}
+		return valType.Kind() == reflect.Interface
+	}
+	value := setting.reflectValue
+	if !value.IsValid() {
+		return true
+	}
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+// The value of the setting as JSON, which is also valid in YAML and (for the simple types) TOML.
+// Samples never have the default of a secret written out.
+func (w *configWriter) value(setting *cfgSetting) (string, error) {
+	var value interface{}
+	if setting.valueSet && !(w.sample && setting.secret) {
+		value = setting.textValue()
+	} else {
+		valType := setting.reflectType
+		for valType.Kind() == reflect.Ptr {
+			valType = valType.Elem()
+		}
+		value = textValue(reflect.Zero(valType), setting.layout)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice && rv.Len() == 0 {
+		return "[]", nil
+	}
+	if rv.Kind() == reflect.Map && rv.Len() == 0 {
+		return "{}", nil
+	}
+	str, err := jsonValue(value)
+	if err != nil {
+		return "", fmt.Errorf("error encoding the value of %s: %w", setting.path, err)
+	}
+	return str, nil
+}
+
+// The value of the setting to be encoded. Durations, times with a layout, URLs and the like are
+// the strings they are parsed from, rather than how json encodes them, eg "1m30s" instead of
+// 90000000000.
+func (s *cfgSetting) textValue() interface{} {
+	return textValue(s.reflectValue, s.layout)
+}
+
+func textValue(value reflect.Value, layout string) interface{} {
+	if !value.IsValid() {
+		return nil
+	}
+	if isParsedType(value.Type()) {
+		return parsedTypeText(value)
+	}
+	switch v := value.Interface().(type) {
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		if layout != "" {
+			return v.Format(layout)
+		}
+		return v
+	}
+	switch value.Kind() {
+	case reflect.Ptr:
+		if !value.IsNil() && (value.Elem().Type() == durationType || value.Elem().Type() == timeType) {
+			return textValue(value.Elem(), layout)
+		}
+	case reflect.Slice:
+		if isTextType(value.Type().Elem()) {
+			list := make([]interface{}, value.Len())
+			for idx := range list {
+				list[idx] = textValue(value.Index(idx), layout)
+			}
+			return list
+		}
+	case reflect.Map:
+		if isTextType(value.Type().Elem()) {
+			m := make(map[string]interface{}, value.Len())
+			iter := value.MapRange()
+			for iter.Next() {
+				m[iter.Key().String()] = textValue(iter.Value(), layout)
+			}
+			return m
+		}
+	}
+	return value.Interface()
+}
+
+// Are values of the type written as text by textValue, when they are in a list or map
+func isTextType(valType reflect.Type) bool {
+	return valType == durationType || isParsedType(valType)
+}
+
+func jsonValue(value interface{}) (string, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+// The value of the setting in TOML, which is the same as JSON apart from maps, which are inline
+// tables, eg { "a" = 1, "b" = 2 }
+func (w *configWriter) tomlValue(setting *cfgSetting) (string, error) {
+	if setting.fieldType != fieldTypeMap || !setting.valueSet || (w.sample && setting.secret) || setting.reflectValue.Len() == 0 {
+		return w.value(setting)
+	}
+	m := reflect.ValueOf(setting.textValue())
+	keys := make([]string, 0, m.Len())
+	for _, key := range m.MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for idx, key := range keys {
+		value, err := jsonValue(m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key())).Interface())
+		if err != nil {
+			return "", fmt.Errorf("error encoding the value of %s: %w", setting.path, err)
+		}
+		name, _ := jsonValue(key)
+		parts[idx] = name + " = " + value
+	}
+	return "{ " + strings.Join(parts, ", ") + " }", nil
+}
+
+// Writes the help of the setting as comments, and if it's required, a note to set it. Only
+// samples have comments.
+func (w *configWriter) writeComments(b *strings.Builder, setting *cfgSetting, indent string) {
+	if !w.sample {
+		return
+	}
+	help := setting.help
+	if constraints := setting.constraintsHelp(); constraints != "" {
+		help = strings.TrimSpace(help + " " + constraints)
+	}
+	for _, line := range strings.Split(help, "\n") {
+		if line != "" {
+			fmt.Fprintf(b, "%s# %s\n", indent, line)
+		}
+	}
+	if w.placeholder(setting) {
+		fmt.Fprintf(b, "%s# Required, uncomment and set:\n", indent)
+	}
+}
+
+// A blank line before settings with comments, to make it clear which setting they are for
+func (w *configWriter) separate(setting *cfgSetting) bool {
+	return w.sample && (setting.help != "" || setting.required || setting.fieldType == fieldTypeSubsection)
+}
+
+func (w *configWriter) writeJson(b *strings.Builder, settings cfgSettings, indent string) error {
+	b.WriteString("{\n")
+	sample := w.settings(settings)
+	for idx, setting := range sample {
+		key, _ := json.Marshal(setting.configKey())
+		fmt.Fprintf(b, "%s  %s: ", indent, key)
+		if setting.fieldType == fieldTypeSubsection {
+			err := w.writeJson(b, setting.subsection, indent+"  ")
+			if err != nil {
+				return err
+			}
+		} else if w.placeholder(setting) {
+			fmt.Fprintf(b, "%q", requiredPlaceholder)
+		} else {
+			value, err := w.value(setting)
+			if err != nil {
+				return err
+			}
+			b.WriteString(value)
+		}
+		if idx < len(sample)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "%s}", indent)
+	if indent == "" {
+		b.WriteString("\n")
+	}
+	return nil
+}
+
+func (w *configWriter) writeYaml(b *strings.Builder, settings cfgSettings, indent string) error {
+	for idx, setting := range w.settings(settings) {
+		if idx > 0 && w.separate(setting) {
+			b.WriteString("\n")
+		}
+		w.writeComments(b, setting, indent)
+		if setting.fieldType == fieldTypeSubsection {
+			fmt.Fprintf(b, "%s%s:\n", indent, setting.configKey())
+			err := w.writeYaml(b, setting.subsection, indent+"  ")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		value, err := w.value(setting)
+		if err != nil {
+			return err
+		}
+		if w.placeholder(setting) {
+			fmt.Fprintf(b, "%s# %s: %s\n", indent, setting.configKey(), value)
+		} else {
+			fmt.Fprintf(b, "%s%s: %s\n", indent, setting.configKey(), value)
+		}
+	}
+	return nil
+}
+
+// The table is the name of the enclosing table, eg database. All the keys of a table must be
+// before the subtables.
+func (w *configWriter) writeToml(b *strings.Builder, settings cfgSettings, table string) error {
+	var subsections []*cfgSetting
+	first := true
+	for _, setting := range w.settings(settings) {
+		if setting.fieldType == fieldTypeSubsection {
+			subsections = append(subsections, setting)
+			continue
+		}
+		if !first && w.separate(setting) {
+			b.WriteString("\n")
+		}
+		first = false
+		w.writeComments(b, setting, "")
+		value, err := w.tomlValue(setting)
+		if err != nil {
+			return err
+		}
+		if w.placeholder(setting) {
+			fmt.Fprintf(b, "# %s = %s\n", setting.configKey(), value)
+		} else if w.isNil(setting) {
+			fmt.Fprintf(b, "# %s = \"\"\n", setting.configKey())
+		} else {
+			fmt.Fprintf(b, "%s = %s\n", setting.configKey(), value)
+		}
+	}
+	for _, setting := range subsections {
+		name := strings.TrimPrefix(table+"."+setting.configKey(), ".")
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+		w.writeComments(b, setting, "")
+		fmt.Fprintf(b, "[%s]\n", name)
+		err := w.writeToml(b, setting.subsection, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package configape
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type sampleConfig struct {
+	Name     string   `required:"true" help:"Your name"`
+	Port     int      `default:"8080" help:"Port to listen on" min:"1"`
+	Tags     []string `default:"a,b"`
+	Password string   `secret:"true" default:"hunter2"`
+	Config   string   `cfgtype:"configfile"`
+	Debug    bool     `hidden:"true"`
+	Database struct {
+		Host string `default:"localhost" help:"Database host"`
+		Pool struct {
+			Size int `default:"5"`
+		}
+	} `help:"Database settings"`
+}
+
+func TestGenerateConfigFile(t *testing.T) {
+	yaml, err := GenerateConfigFile(&sampleConfig{}, "yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# Your name
+# Required, uncomment and set:
+# name: ""
+
+# Port to listen on (min: 1)
+port: 8080
+tags: ["a","b"]
+password: ""
+
+# Database settings
+database:
+  # Database host
+  host: "localhost"
+
+  pool:
+    size: 5
+`
+	if yaml != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, yaml)
+	}
+
+	json, _ := GenerateConfigFile(&sampleConfig{}, "json", nil)
+	if !strings.Contains(json, `"name": "<required>",`) || !strings.Contains(json, `"pool": {`) {
+		t.Errorf("expected the json to have the required placeholder and subsections:\n%s", json)
+	}
+	toml, _ := GenerateConfigFile(&sampleConfig{}, "toml", nil)
+	if !strings.Contains(toml, "# name = \"\"\n") || !strings.Contains(toml, "[database.pool]\nsize = 5\n") {
+		t.Errorf("expected the toml to have the required setting commented out and subtables:\n%s", toml)
+	}
+
+	_, err = GenerateConfigFile(&sampleConfig{}, "ini", nil)
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+// The generated files can be read back in, giving the defaults
+func TestGenerateConfigFileRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "yaml", "toml"} {
+		contents, err := GenerateConfigFile(&sampleConfig{}, format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The json placeholder is a valid string, so it is replaced from the command line
+		cfg := sampleConfig{}
+		err = Apply(&cfg, &Options{
+			ConfigFilename:     "test." + format,
+			cfgFileContents:    contents,
+			DisableEnviornment: true,
+			osArgs:             []string{"cfgape", "--name", "bob"},
+		})
+		if err != nil {
+			t.Errorf("%s: %v\n%s", format, err, contents)
+			continue
+		}
+		if cfg.Port != 8080 || len(cfg.Tags) != 2 || cfg.Database.Host != "localhost" || cfg.Database.Pool.Size != 5 {
+			t.Errorf("%s: expected the defaults, got %+v", format, cfg)
+		}
+	}
+}
+
+// TOML has no null, so values that aren't set are commented out rather than null
+func TestGenerateConfigFileNil(t *testing.T) {
+	type nilConfig struct {
+		Name  string `default:"bob"`
+		Extra interface{}
+	}
+	for _, format := range []string{"json", "yaml", "toml"} {
+		contents, err := GenerateConfigFile(&nilConfig{}, format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := nilConfig{}
+		err = Apply(&cfg, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil || cfg.Name != "bob" || cfg.Extra != nil {
+			t.Errorf("%s: expected the sample to load, got %+v: %v\n%s", format, cfg, err, contents)
+		}
+		if format == "toml" && !strings.Contains(contents, "# extra = \"\"\n") {
+			t.Errorf("expected the nil value to be commented out:\n%s", contents)
+		}
+	}
+}
+
+func TestGenerateConfigFlag(t *testing.T) {
+	buffer := &strings.Builder{}
+	cfg := sampleConfig{}
+	err := Apply(&cfg, &Options{
+		ConfigFilename:       "test.toml",
+		cfgFileContents:      "port = 1\n",
+		EnableGenerateConfig: true,
+		HelpWriter:           buffer,
+		osArgs:               []string{"cfgape", "--generate-config"},
+	})
+	if !errors.Is(err, ErrGenerateConfigRequested) {
+		t.Fatalf("expected ErrGenerateConfigRequested, got: %v", err)
+	}
+	// The format of the config file, with the defaults rather than the current values
+	if !strings.Contains(buffer.String(), "port = 8080\n") {
+		t.Errorf("expected a toml config file:\n%s", buffer.String())
+	}
+
+	buffer.Reset()
+	err = Apply(&cfg, &Options{DisableConfigFile: true, EnableGenerateConfig: true, HelpWriter: buffer, osArgs: []string{"cfgape", "--generate-config=json"}})
+	if !errors.Is(err, ErrGenerateConfigRequested) || !strings.HasPrefix(buffer.String(), "{\n") {
+		t.Errorf("expected a json config file, got %v:\n%s", err, buffer.String())
+	}
+
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableHelpOnMissingRequired: true, osArgs: []string{"cfgape", "--generate-config"}})
+	var unknown *ErrUnknownArgument
+	if !errors.As(err, &unknown) {
+		t.Errorf("expected --generate-config to be unknown unless enabled, got: %v", err)
+	}
+}
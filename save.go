@@ -0,0 +1,100 @@
+package configape
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+// Save writes the values in cfg to the config file at path, in the format of the file extension
+// (or Options.ConfigFileType), with the same keys that Apply reads. The file is written to a
+// temporary file that is renamed over path, so it is never left half written. Set
+// Options.SaveSkipDefaults to leave out the settings with their default value, and
+// Options.SaveOmitSecrets to leave out the secrets.
+func Save(cfg interface{}, path string, options *Options) error {
+	c := cfgApe{}
+	c.cfg = cfg
+	if options == nil {
+		options = &Options{}
+	}
+	c.options = *options
+
+	err := c.parseStructIntoSettings()
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(c.cfg)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	getValues(value, c.settings)
+
+	w := &configWriter{skipDefaults: c.options.SaveSkipDefaults, omitSecrets: c.options.SaveOmitSecrets}
+	str, err := w.write(c.settings, c.configFileType(path))
+	if err != nil {
+		return fmt.Errorf("error saving config file %s: %w", path, err)
+	}
+	// Secrets shouldn't be readable by everyone
+	mode := os.FileMode(0644)
+	if !c.options.SaveOmitSecrets && c.settings.hasSecrets() {
+		mode = 0600
+	}
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	return writeFileAtomic(path, []byte(str), mode)
+}
+
+// The opposite of setValues, sets the settings to the values in the struct. Nil pointers are
+// left unset, as are the commands.
+func getValues(value reflect.Value, settings cfgSettings) {
+	for i := 0; i < len(settings); i++ {
+		setting := &settings[i]
+		field := value.Field(setting.idx)
+		if setting.fieldType == fieldTypeSubsection {
+			getValues(field, setting.subsection)
+			continue
+		}
+		if setting.isCommand() || (field.Kind() == reflect.Ptr && field.IsNil()) {
+			continue
+		}
+		setting.reflectValue = field
+		setting.valueSet = true
+	}
+}
+
+func (s cfgSettings) hasSecrets() bool {
+	for i := 0; i < len(s); i++ {
+		if s[i].secret || (s[i].fieldType == fieldTypeSubsection && s[i].subsection.hasSecrets()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes the file to a temporary file in the same directory, and then renames it to path.
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	fh, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpName := fh.Name()
+	// Only does anything if it went wrong
+	defer os.Remove(tmpName)
+
+	_, err = fh.Write(data)
+	if err == nil {
+		err = fh.Sync()
+	}
+	if closeErr := fh.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, mode)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
@@ -0,0 +1,88 @@
+package configape
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type saveConfig struct {
+	Name     string `required:"true"`
+	Port     int    `default:"8080"`
+	Tags     []string
+	Ratio    *float64
+	Password string `secret:"true"`
+	Debug    bool   `hidden:"true"`
+	Extra    interface{}
+	Database struct {
+		Host string `default:"localhost"`
+		User string `name:"username"`
+	}
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	ratio := 0.5
+	cfg := saveConfig{Name: "bob", Port: 80, Tags: []string{"a", "b"}, Ratio: &ratio, Password: "hunter2", Debug: true}
+	cfg.Database.Host = "db"
+	cfg.Database.User = "alice"
+
+	// It can be read back in each format
+	for _, format := range []string{"json", "yaml", "toml"} {
+		path := filepath.Join(dir, "config."+format)
+		err := Save(&cfg, path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded := saveConfig{}
+		err = Apply(&loaded, &Options{ConfigFilename: path, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if !reflect.DeepEqual(loaded, cfg) {
+			t.Errorf("%s: expected %+v, got %+v", format, cfg, loaded)
+		}
+		// There are secrets, so only the owner can read it
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("%s: expected mode 0600, got %s", format, info.Mode())
+		}
+	}
+	// The temporary files are gone
+	files, _ := os.ReadDir(dir)
+	if len(files) != 3 {
+		t.Errorf("expected just the three config files, got %d", len(files))
+	}
+}
+
+func TestSaveOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	cfg := saveConfig{Name: "bob", Port: 8080, Password: "hunter2"}
+	cfg.Database.Host = "localhost"
+	err := Save(&cfg, path, &Options{SaveSkipDefaults: true, SaveOmitSecrets: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, _ := os.ReadFile(path)
+	if string(contents) != "name: \"bob\"\n" {
+		t.Errorf("expected just the name, got:\n%s", contents)
+	}
+	// The mode of an existing file is kept
+	os.Chmod(path, 0640)
+	cfg.Name = "alice"
+	Save(&cfg, path, &Options{SaveSkipDefaults: true})
+	contents, _ = os.ReadFile(path)
+	if !strings.Contains(string(contents), "name: \"alice\"\n") || !strings.Contains(string(contents), "password: \"hunter2\"\n") {
+		t.Errorf("expected the name and password, got:\n%s", contents)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("expected mode 0640, got %s", info.Mode())
+	}
+
+	err = Save(&cfg, filepath.Join(t.TempDir(), "config.ini"), nil)
+	if err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
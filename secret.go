@@ -0,0 +1,37 @@
+package configape
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// What secret values are replaced with when they are displayed.
+const redacted = "******"
+
+// Returns str, unless the setting is a secret in which case it is redacted.
+func (s *cfgSetting) redact(str string) string {
+	if s.secret && str != "" {
+		return redacted
+	}
+	return str
+}
+
+// Most parsing errors include the value that failed to parse, so if the setting is a secret and
+// the error has the value in it, it is replaced with one that doesn't.
+func (s *cfgSetting) redactError(err error, value string) error {
+	if err == nil || !s.secret || value == "" || !strings.Contains(err.Error(), value) {
+		return err
+	}
+	return fmt.Errorf("invalid value for %s", s.reflectType)
+}
+
+// Reads a secret from a file, as used by docker and kubernetes secrets. Trailing newlines
+// are removed as they are almost never part of the secret.
+func readSecretFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(contents), "\r\n"), nil
+}
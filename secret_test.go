@@ -0,0 +1,166 @@
+package configape
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecretRedaction(t *testing.T) {
+	type config struct {
+		User     string
+		Password string `secret:"true" default:"hunter2"`
+		Pin      int    `secret:"true"`
+	}
+	cfg := config{}
+	help, err := Help(&cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(help, "hunter2") || !strings.Contains(help, "(default: ******)") {
+		t.Errorf("help did not redact the default:\n%s", help)
+	}
+
+	// Parse errors must not contain the value
+	err = Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--pin=sekrit"},
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "sekrit") {
+		t.Errorf("error contained the secret: %s", err)
+	}
+	// Only the value is redacted, even when it is also part of the flag
+	err = Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--pin=pi"},
+	})
+	expected := "failed to parse --pin=****** into cfg.Pin: invalid value for int"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got: %v", expected, err)
+	}
+	var parseErr *ErrParseValue
+	if !errors.As(err, &parseErr) || parseErr.Source.Name != "--pin" || parseErr.Input != redacted {
+		t.Errorf("expected just the flag and the redacted input, got: %+v", parseErr)
+	}
+	// Nor a misspelt argument, or a value that isn't valid in the config file
+	err = Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--pasword=sekrit"},
+	})
+	if err == nil || err.Error() != "unknown command line argument: --pasword" {
+		t.Errorf("expected an unknown argument without the value, got: %v", err)
+	}
+	err = Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		ConfigFilename:     "test.toml",
+		cfgFileContents:    "user = \"bob\"\npassword = sekrit\n",
+		osArgs:             []string{"cfgape"},
+	})
+	var syntaxErr *ErrConfigSyntax
+	if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 || strings.Contains(err.Error(), "sekrit") {
+		t.Errorf("expected a syntax error on line 2 without the value, got: %v", err)
+	}
+	os.Setenv("CFG_PIN", "sekrit")
+	err = Apply(&cfg, &Options{
+		DisableConfigFile:  true,
+		DisableCommandLine: true,
+	})
+	os.Unsetenv("CFG_PIN")
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if strings.Contains(err.Error(), "sekrit") {
+		t.Errorf("error contained the secret: %s", err)
+	}
+
+	// Nor should the provenance
+	provenance, err := Explain(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--password=letmein", "--user=bob"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range provenance {
+		if strings.Contains(p.String(), "letmein") {
+			t.Errorf("provenance contained the secret: %s", p)
+		}
+	}
+	if provenance[1].Value != redacted {
+		t.Errorf("password was not redacted: %s", provenance[1])
+	}
+	if provenance[0].Value != `"bob"` {
+		t.Errorf("user should not be redacted: %s", provenance[0])
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	passwordFile := filepath.Join(dir, "password")
+	os.WriteFile(passwordFile, []byte("from-file\n"), 0600)
+	cfg := struct {
+		Database struct {
+			Password string `secret:"true"`
+		}
+		TokenFile string
+	}{}
+
+	os.Setenv("CFG_DATABASE_PASSWORD_FILE", passwordFile)
+	os.Setenv("CFG_TOKEN_FILE", "not-a-secret")
+	defer os.Unsetenv("CFG_DATABASE_PASSWORD_FILE")
+	defer os.Unsetenv("CFG_TOKEN_FILE")
+	err := Apply(&cfg, &Options{DisableConfigFile: true, DisableCommandLine: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "from-file" {
+		t.Errorf("password was not read from the file: %q", cfg.Database.Password)
+	}
+	if cfg.TokenFile != "not-a-secret" {
+		t.Errorf("token file was not set: %q", cfg.TokenFile)
+	}
+
+	// Setting it directly wins over the file
+	os.Setenv("CFG_DATABASE_PASSWORD", "direct")
+	defer os.Unsetenv("CFG_DATABASE_PASSWORD")
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableCommandLine: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.Password != "direct" {
+		t.Errorf("password was not set directly: %q", cfg.Database.Password)
+	}
+
+	for _, args := range [][]string{
+		{"cfgape", "--database-password-file", passwordFile},
+		{"cfgape", "--database-password-file=" + passwordFile},
+	} {
+		cfg.Database.Password = ""
+		err = Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: args})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Database.Password != "from-file" {
+			t.Errorf("password was not read from the file with %v: %q", args, cfg.Database.Password)
+		}
+	}
+
+	// Only secrets can be read from files
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--token-file-file", passwordFile}})
+	if err == nil {
+		t.Error("expected error")
+	}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--database-password-file", filepath.Join(dir, "missing")}})
+	if err == nil {
+		t.Error("expected error")
+	}
+}
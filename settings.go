@@ -21,6 +21,9 @@ const (
 	fieldTypeConfigFile
 	fieldTypeSubsection
 	fieldTypeCustomMarshaler
+	fieldTypeCommand     // A subcommand, its subsection has the settings for the command
+	fieldTypeCommandPath // Set to the selected command path, eg "migrate up"
+	fieldTypeMap         // A map[string]T, set with key=value
 )
 
 // Each field in the struct is a setting (except ones that are skipped).
@@ -29,24 +32,70 @@ const (
 type cfgSetting struct {
 	idx          int    // The index of the setting in the cfg struct
 	name         string // The name of the setting
+	path         string // The config key path of the setting, eg database.host
 	envName      string // Override for environment variable name
 	cliName      string // Override the name for the cli
 	shortName    string // If it is a cli, then the short name for the setting
 	required     bool   // Is this required
+	secret       bool   // Is this a secret (eg a password), which should never be displayed
+	hidden       bool   // Not shown in the help, completion or documentation
+	group        string // The heading the setting is shown under in the help
+	layout       string // The layout of a time.Time, RFC 3339 if not set
 	defaultValue string // default value
 	help         string
 	fieldType    cfgFieldType
 	reflectType  reflect.Type // The reflect type of the setting
 	subsection   cfgSettings
+	validation   cfgValidation // The constraints from the validation tags
 
 	// These are the results after all the parsing.
 	reflectValue reflect.Value // The raw reflect value of the setting
 	valueSet     bool          // Set true when the value is set.
-	whereSet     string        // Describe where this value came from
+	whereSet     Source        // Describe where this value came from
+}
+
+// The key used for the setting in config files, eg FooBar is foo_bar.
+func (s *cfgSetting) configKey() string {
+	key := strings.ToLower(camelCaseToUnderscore(s.name))
+	return strings.Replace(key, "-", "_", -1)
 }
 
 type cfgSettings []cfgSetting
 
+// The long command line argument for the setting without the dashes or subsection prefix, eg
+// FooBar is foo-bar, unless it has a cli tag.
+func (s *cfgSetting) cliFlagName() string {
+	if s.cliName != "" {
+		return s.cliName
+	}
+	return strings.ToLower(camelCaseToDash(s.name))
+}
+
+// The word used on the command line for a command, eg UserAdd is user-add.
+func (s *cfgSetting) commandName() string {
+	return strings.ToLower(camelCaseToDash(s.name))
+}
+
+// The command line prefix (eg database-) and environment variable sections (eg DATABASE_) of the
+// settings in a subsection, added to those of the subsections it is in.
+func (s *cfgSetting) subsectionNames(prefix string, sections string) (string, string) {
+	return prefix + strings.ToLower(camelCaseToDash(s.name)) + "-", sections + strings.ToUpper(s.name) + "_"
+}
+
+// Sets the path of each of the settings, prefixing the paths of the settings in subsections
+// and commands.
+func (s cfgSettings) setPaths(prefix string) {
+	for i := 0; i < len(s); i++ {
+		s[i].path = prefix + s[i].configKey()
+		if s[i].fieldType == fieldTypeCommand {
+			s[i].path = prefix + s[i].commandName()
+		}
+		if s[i].fieldType == fieldTypeSubsection || s[i].fieldType == fieldTypeCommand {
+			s[i].subsection.setPaths(s[i].path + ".")
+		}
+	}
+}
+
 func (s cfgSettings) Find(name, what string) *cfgSetting {
 	return s.doFind(name, what, false)
 }
@@ -63,9 +112,13 @@ func (s cfgSettings) doFind(name string, what string, doRecursive bool) *cfgSett
 	// and hyphen and underscore could be either case.
 
 	// First check if there is a specific cli or env name for this setting
-	// If so, then use that with an explicit match -- don't lowercase or camelcase compare.
+	// If so, then use that with an explicit match -- don't camelcase compare. Environment
+	// variables are upper case, so the env name is matched in any case.
 	for i := 0; i < len(s); i++ {
 		//debugf("Finding %s, checking %s\n", name, s[i].name)
+		if s[i].isCommand() {
+			continue
+		}
 		var strictMatch string
 		if what == "env" && s[i].envName != "" {
 			strictMatch = s[i].envName
@@ -73,7 +126,7 @@ func (s cfgSettings) doFind(name string, what string, doRecursive bool) *cfgSett
 			strictMatch = s[i].cliName
 		}
 		if strictMatch != "" {
-			if name == strictMatch {
+			if name == strictMatch || (what == "env" && strings.EqualFold(name, strictMatch)) {
 				return &s[i]
 			}
 			if doRecursive {
@@ -98,6 +151,9 @@ func (s cfgSettings) doFind(name string, what string, doRecursive bool) *cfgSett
 	name = strings.ToLower(name)
 	// Now check just the name, checking all the possible forms
 	for i := 0; i < len(s); i++ {
+		if s[i].isCommand() {
+			continue
+		}
 		underscore := strings.ToLower(camelCaseToUnderscore(s[i].name))
 		underscore = strings.Replace(underscore, "-", "_", -1)
 		dash := strings.Replace(underscore, "_", "-", -1)
@@ -132,6 +188,31 @@ func (s cfgSettings) doFind(name string, what string, doRecursive bool) *cfgSett
 	}
 	return nil
 }
+
+// Commands and the command path are only set by the command line words, never by name.
+func (s *cfgSetting) isCommand() bool {
+	return s.fieldType == fieldTypeCommand || s.fieldType == fieldTypeCommandPath
+}
+
+// Find the command with the name as given on the command line.
+func (s cfgSettings) FindCommand(name string) *cfgSetting {
+	for i := 0; i < len(s); i++ {
+		if s[i].fieldType == fieldTypeCommand && s[i].commandName() == strings.ToLower(name) {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+func (s cfgSettings) hasCommands() bool {
+	for i := 0; i < len(s); i++ {
+		if s[i].fieldType == fieldTypeCommand {
+			return true
+		}
+	}
+	return false
+}
+
 func (s cfgSettings) FindShort(name string) *cfgSetting {
 	for i := 0; i < len(s); i++ {
 		if s[i].shortName == name {
@@ -163,14 +244,20 @@ func (s *cfgSettings) SetDefaults() error {
 	for i := 0; i < len(*s); i++ {
 		setting := &(*s)[i]
 		if setting.defaultValue != "" {
-			setting.whereSet = fmt.Sprintf("%s default value", setting.name)
-			setting.reflectValue, err = strToType(setting.reflectType, setting.defaultValue)
+			setting.whereSet = Source{Kind: SourceDefault}
+			setting.reflectValue, err = setting.parseValue(setting.defaultValue)
 			setting.valueSet = true
 			if err != nil {
-				return fmt.Errorf("failed to parse default value for %s: %s", setting.name, err)
+				return &ErrParseValue{
+					Setting: setting.path,
+					Name:    setting.name,
+					Source:  setting.whereSet,
+					Input:   setting.redact(setting.defaultValue),
+					Err:     setting.redactError(err, setting.defaultValue),
+				}
 			}
 		}
-		if setting.fieldType == fieldTypeSubsection {
+		if setting.fieldType == fieldTypeSubsection || setting.fieldType == fieldTypeCommand {
 			err := setting.subsection.SetDefaults()
 			if err != nil {
 				return err
@@ -179,24 +266,40 @@ func (s *cfgSettings) SetDefaults() error {
 	}
 	return nil
 }
+
+// Checks that all the required settings have been set, returning a MultiError of all of those that
+// haven't.
 func (s cfgSettings) CheckRequired() error {
+	errs := &MultiError{}
+	s.checkRequired("", errs)
+	return errs.errorOrNil()
+}
+
+func (s cfgSettings) checkRequired(prefix string, errs *MultiError) {
 	for i := 0; i < len(s); i++ {
 		setting := &s[i]
 		if setting.required && !setting.valueSet {
-			return fmt.Errorf("required setting %s not set", setting.name)
+			errs.add(&ErrRequired{Setting: setting.path, Name: prefix + setting.name})
 		}
 		if setting.fieldType == fieldTypeSubsection {
-			err := setting.subsection.CheckRequired()
-			if err != nil {
-				return err
-			}
+			setting.subsection.checkRequired(prefix+setting.configKey()+".", errs)
+		}
+		// Only the settings of the selected commands are required
+		if setting.fieldType == fieldTypeCommand && setting.valueSet {
+			setting.subsection.checkRequired(prefix+setting.commandName()+".", errs)
 		}
 	}
-	return nil
 }
 
 // Reads the cfg struct and creates the settings that represents the struct
 func (c *cfgApe) parseStructIntoSettings() error {
+	// A *Live[T] is used as its current configuration, or a new one if it hasn't been applied yet
+	if live, ok := c.cfg.(liveConfig); ok {
+		c.cfg = live.current()
+		if c.cfg == nil {
+			c.cfg = live.newConfig()
+		}
+	}
 	typeOfCfg := reflect.TypeOf(c.cfg)
 	if typeOfCfg.Kind() == reflect.Ptr {
 		typeOfCfg = typeOfCfg.Elem()
@@ -209,6 +312,7 @@ func (c *cfgApe) parseStructIntoSettings() error {
 	if err != nil {
 		return err
 	}
+	c.settings.setPaths("")
 	return nil
 }
 
@@ -248,6 +352,13 @@ func structToSettings(cfgType reflect.Type) (cfgSettings, error) {
 		if required := field.Tag.Get("required"); required != "" {
 			setting.required = true
 		}
+		if secret := field.Tag.Get("secret"); secret != "" && secret != "false" {
+			setting.secret = true
+		}
+		if hidden := field.Tag.Get("hidden"); hidden != "" && hidden != "false" {
+			setting.hidden = true
+		}
+		setting.group = field.Tag.Get("group")
 		if defaultVal := field.Tag.Get("default"); defaultVal != "" {
 			setting.defaultValue = defaultVal
 		}
@@ -263,13 +374,43 @@ func structToSettings(cfgType reflect.Type) (cfgSettings, error) {
 		if cliName := field.Tag.Get("cli"); cliName != "" {
 			setting.cliName = cliName
 		}
+		if layout := field.Tag.Get("layout"); layout != "" {
+			if field.Type != timeType && field.Type != reflect.PtrTo(timeType) {
+				return nil, fmt.Errorf("struct field %s, layout can only be used on time.Time", field.Name)
+			}
+			setting.layout = layout
+		}
+		if err := parseValidationTags(field, &setting); err != nil {
+			return nil, err
+		}
 
 		// make ptrType the type of a pointer to fieldType
 		// (As Unmarshal needs a pointer to the type)
 		ptrType := reflect.PtrTo(field.Type)
 
-		// If field implements any of the unmarshallers, then it's not a subsection.
-		if field.Tag.Get("cfgtype") != "subsection" && (ptrType.Implements(jsonUnmarshaler) || ptrType.Implements(yamlUnmarshaler) || ptrType.Implements(textUnmarshaler)) {
+		if tagType := field.Tag.Get("cfgtype"); tagType == "command" {
+			// A command is a struct, or pointer to a struct that is only allocated if the command
+			// is selected, with the settings for that command.
+			commandType := field.Type
+			if commandType.Kind() == reflect.Ptr {
+				commandType = commandType.Elem()
+			}
+			if commandType.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("struct field %s, command must be a struct or pointer to a struct", field.Name)
+			}
+			subsettings, err := structToSettings(commandType)
+			if err != nil {
+				return nil, err
+			}
+			setting.subsection = subsettings
+			setting.fieldType = fieldTypeCommand
+		} else if tagType == "commandpath" {
+			if field.Type.Kind() != reflect.String && (field.Type.Kind() != reflect.Slice || field.Type.Elem().Kind() != reflect.String) {
+				return nil, fmt.Errorf("struct field %s, commandpath must be a string or []string", field.Name)
+			}
+			setting.fieldType = fieldTypeCommandPath
+		} else if tagType != "subsection" && (ptrType.Implements(jsonUnmarshaler) || ptrType.Implements(yamlUnmarshaler) || ptrType.Implements(textUnmarshaler) || isParsedType(field.Type)) {
+			// If field implements any of the unmarshallers, then it's not a subsection.
 			// We need to detect and flag if the field has a custom unmarshaler, as we can't recurse into it, like we do
 			// for the structs.
 			setting.fieldType = fieldTypeCustomMarshaler
@@ -301,6 +442,11 @@ func structToSettings(cfgType reflect.Type) (cfgSettings, error) {
 			setting.fieldType = fieldTypeFlag
 		} else if field.Type.Kind() == reflect.Slice {
 			setting.fieldType = fieldTypeList
+		} else if field.Type.Kind() == reflect.Map {
+			if field.Type.Key().Kind() != reflect.String {
+				return nil, fmt.Errorf("struct field %s, maps must have string keys", field.Name)
+			}
+			setting.fieldType = fieldTypeMap
 		} else if fieldType := field.Tag.Get("cfgtype"); fieldType != "" {
 			switch fieldType {
 			case "configfile":
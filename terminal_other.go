@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package configape
+
+import "os"
+
+// Returns the width of the terminal, which isn't known on this platform.
+func terminalWidth(file *os.File) int {
+	return 0
+}
@@ -0,0 +1,98 @@
+package configape
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Types that are parsed from strings here, rather than by their own unmarshalers, either because
+// they don't have one (url.URL) or to give better errors. Pointers to them work too. The parsers
+// return the type itself, not a pointer.
+var textParsers = map[reflect.Type]func(str string) (interface{}, error){
+	reflect.TypeOf(net.IP{}):         parseIP,
+	reflect.TypeOf(netip.Addr{}):     parseAddr,
+	reflect.TypeOf(netip.AddrPort{}): parseAddrPort,
+	reflect.TypeOf(netip.Prefix{}):   parsePrefix,
+	reflect.TypeOf(url.URL{}):        parseURL,
+	reflect.TypeOf(regexp.Regexp{}):  parseRegexp,
+}
+
+// Is the type (or what it points to) one that is in textParsers
+func isParsedType(valType reflect.Type) bool {
+	if valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	_, ok := textParsers[valType]
+	return ok
+}
+
+func parseIP(str string) (interface{}, error) {
+	ip := net.ParseIP(strings.TrimSpace(str))
+	if ip == nil {
+		return nil, fmt.Errorf("invalid IP address: %s", str)
+	}
+	return ip, nil
+}
+
+func parseAddr(str string) (interface{}, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(str))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IP address: %s", str)
+	}
+	return addr, nil
+}
+
+func parseAddrPort(str string) (interface{}, error) {
+	addrPort, err := netip.ParseAddrPort(strings.TrimSpace(str))
+	if err != nil {
+		return nil, fmt.Errorf("invalid address and port: %s (expected eg 127.0.0.1:8080 or [::1]:8080)", str)
+	}
+	return addrPort, nil
+}
+
+func parsePrefix(str string) (interface{}, error) {
+	prefix, err := netip.ParsePrefix(strings.TrimSpace(str))
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR prefix: %s (expected eg 10.0.0.0/8)", str)
+	}
+	return prefix, nil
+}
+
+func parseURL(str string) (interface{}, error) {
+	u, err := url.Parse(strings.TrimSpace(str))
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %s", strings.TrimPrefix(err.Error(), "parse "))
+	}
+	return *u, nil
+}
+
+func parseRegexp(str string) (interface{}, error) {
+	re, err := regexp.Compile(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %s", strings.TrimPrefix(err.Error(), "error parsing regexp: "))
+	}
+	return *re, nil
+}
+
+// The text of a value of one of the textParsers types, eg a url.URL is "https://example.com". The
+// zero value is empty, rather than eg "invalid IP".
+func parsedTypeText(value reflect.Value) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if value.IsZero() {
+		return ""
+	}
+	// The String methods of url.URL and regexp.Regexp need a pointer
+	ptr := reflect.New(value.Type())
+	ptr.Elem().Set(value)
+	return fmt.Sprint(ptr.Interface())
+}
@@ -0,0 +1,229 @@
+package configape
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type netConfig struct {
+	Bind    net.IP         `default:"127.0.0.1"`
+	Peer    netip.Addr     `default:"::1"`
+	Listen  netip.AddrPort `default:"0.0.0.0:8080"`
+	Allow   []netip.Prefix `default:"10.0.0.0/8,192.168.0.0/16"`
+	Proxies []net.IP
+	API     *url.URL `default:"https://api.example.com/v1" schemes:"http,https"`
+	Home    url.URL
+	Match   *regexp.Regexp `default:"^a+$"`
+}
+
+func TestParsedTypes(t *testing.T) {
+	cfg := netConfig{}
+	err := Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Bind.Equal(net.IPv4(127, 0, 0, 1)) || cfg.Peer != netip.IPv6Loopback() || cfg.Listen.Port() != 8080 {
+		t.Errorf("expected the default addresses, got %v %v %v", cfg.Bind, cfg.Peer, cfg.Listen)
+	}
+	if len(cfg.Allow) != 2 || cfg.Allow[1] != netip.MustParsePrefix("192.168.0.0/16") {
+		t.Errorf("expected the default prefixes, got %v", cfg.Allow)
+	}
+	if cfg.API == nil || cfg.API.Host != "api.example.com" || cfg.Match == nil || !cfg.Match.MatchString("aaa") {
+		t.Errorf("expected the default url and regexp, got %v %v", cfg.API, cfg.Match)
+	}
+
+	os.Setenv("CFG_PROXIES", "10.0.0.1,::2")
+	defer os.Unsetenv("CFG_PROXIES")
+	cfg = netConfig{}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "--allow", "172.16.0.0/12", "--allow", "fd00::/8", "--home", "/index.html", "--api", "http://localhost:9000"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Proxies) != 2 || !cfg.Proxies[1].Equal(net.ParseIP("::2")) {
+		t.Errorf("expected the proxies from the environment, got %v", cfg.Proxies)
+	}
+	if len(cfg.Allow) != 4 || cfg.Allow[2] != netip.MustParsePrefix("172.16.0.0/12") || cfg.Allow[3] != netip.MustParsePrefix("fd00::/8") {
+		t.Errorf("expected the prefixes from the command line to be added, got %v", cfg.Allow)
+	}
+	if cfg.Home.Path != "/index.html" || cfg.API.Port() != "9000" {
+		t.Errorf("expected the urls from the command line, got %v %v", cfg.Home, cfg.API)
+	}
+
+	errs := map[string]string{
+		"--bind=300.1.1.1":     "invalid IP address: 300.1.1.1",
+		"--peer=localhost":     "invalid IP address: localhost",
+		"--listen=127.0.0.1":   "invalid address and port: 127.0.0.1 (expected eg 127.0.0.1:8080 or [::1]:8080)",
+		"--allow=10.0.0.0":     "invalid CIDR prefix: 10.0.0.0 (expected eg 10.0.0.0/8)",
+		"--api=http://a b":     `invalid URL: "http://a b": invalid character " " in host name`,
+		"--match=(":            "invalid regular expression: missing closing ): `(`",
+		"--api=ftp://a.com/x":  "invalid value for api from command line --api: scheme must be one of: http, https",
+		"--api=/relative/path": "scheme must be one of: http, https",
+	}
+	for arg, expected := range errs {
+		err = Apply(&netConfig{}, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", arg}})
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected the error %q, got: %v", arg, expected, err)
+		}
+	}
+	var parseErr *ErrParseValue
+	err = Apply(&netConfig{}, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--bind", "nope"}})
+	if !errors.As(err, &parseErr) || parseErr.Setting != "bind" {
+		t.Errorf("expected the error to name the setting, got: %v", err)
+	}
+
+	_, err = structToSettings(reflect.TypeOf(struct {
+		Bad string `schemes:"http"`
+	}{}))
+	if err == nil {
+		t.Error("expected an error for schemes on a string")
+	}
+}
+
+func TestParsedTypesConfigFiles(t *testing.T) {
+	files := map[string]string{
+		"json": `{"bind": "::1", "allow": ["10.1.0.0/16"], "api": "https://example.com/api", "home": "https://example.com", "match": "^b$"}`,
+		"yaml": "bind: ::1\nallow:\n  - 10.1.0.0/16\napi: https://example.com/api\nhome: https://example.com\nmatch: ^b$\n",
+		"toml": "bind = \"::1\"\nallow = [\"10.1.0.0/16\"]\napi = \"https://example.com/api\"\nhome = \"https://example.com\"\nmatch = \"^b$\"\n",
+	}
+	for format, contents := range files {
+		cfg := netConfig{}
+		err := Apply(&cfg, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if !cfg.Bind.Equal(net.IPv6loopback) || len(cfg.Allow) != 1 || cfg.Allow[0].Bits() != 16 {
+			t.Errorf("%s: expected the addresses from the file, got %v %v", format, cfg.Bind, cfg.Allow)
+		}
+		if cfg.API.Path != "/api" || cfg.Home.Host != "example.com" || !cfg.Match.MatchString("b") {
+			t.Errorf("%s: expected the url and regexp from the file, got %v %v %v", format, cfg.API, cfg.Home, cfg.Match)
+		}
+	}
+
+	err := Apply(&netConfig{}, &Options{ConfigFilename: "test.json", cfgFileContents: `{"allow": ["10.1.0.0/16", "nope"]}`, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid CIDR prefix: nope") {
+		t.Errorf("expected a prefix error, got: %v", err)
+	}
+
+	// Written out as text, and read back
+	dir := t.TempDir()
+	cfg := netConfig{}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--home", "https://example.com/home"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"json", "yaml", "toml"} {
+		path := filepath.Join(dir, "config."+format)
+		err := Save(&cfg, path, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		contents, _ := os.ReadFile(path)
+		for _, want := range []string{`"127.0.0.1"`, `"0.0.0.0:8080"`, `"10.0.0.0/8"`, `"https://api.example.com/v1"`, `"https://example.com/home"`, `"^a+$"`} {
+			if !strings.Contains(string(contents), want) {
+				t.Errorf("%s: expected %s in:\n%s", format, want, contents)
+			}
+		}
+		loaded := netConfig{}
+		err = Apply(&loaded, &Options{ConfigFilename: path, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if loaded.Home.String() != "https://example.com/home" || loaded.API.String() != cfg.API.String() {
+			t.Errorf("%s: expected the saved values, got %v %v", format, loaded.Home, loaded.API)
+		}
+	}
+
+	// And the sample doesn't have "invalid IP" for the addresses without a default
+	str, err := GenerateConfigFile(&netConfig{}, "yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(str, `home: ""`) || strings.Contains(str, "invalid") {
+		t.Errorf("expected empty values in the sample:\n%s", str)
+	}
+}
+
+func TestParsedTypesHelp(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	cfg := netConfig{}
+	err := Apply(&cfg, &Options{Name: "test", HelpWriter: buffer, HelpWidth: 100, DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--help"}})
+	if err != ErrHelpRequested {
+		t.Fatalf("expected the help, got: %v", err)
+	}
+	if !strings.Contains(buffer.String(), "--api <API> (default: https://api.example.com/v1) (schemes: http, https)") {
+		t.Errorf("expected the default and schemes in the help:\n%s", buffer)
+	}
+
+	explained, err := Explain(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range explained {
+		if p.Path == "api" && p.Value == "https://api.example.com/v1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the url in the explanation: %v", explained)
+	}
+
+	schema, err := JSONSchema(&cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"format": "uri"`, `"format": "regex"`, `"default": "127.0.0.1"`} {
+		if !strings.Contains(schema, want) {
+			t.Errorf("expected %s in the schema:\n%s", want, schema)
+		}
+	}
+}
+
+func TestParsedTypesZeroRoundTrip(t *testing.T) {
+	type zeroConfig struct {
+		Bind   net.IP
+		Peer   netip.Addr
+		Listen netip.AddrPort
+		Allow  netip.Prefix
+		API    *url.URL `schemes:"https"`
+		Home   url.URL  `schemes:"https"`
+		Match  *regexp.Regexp
+	}
+	dir := t.TempDir()
+	for _, format := range []string{"json", "yaml", "toml"} {
+		// Saved without values, and read back as still not set
+		path := filepath.Join(dir, "saved."+format)
+		err := Save(&zeroConfig{}, path, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		loaded := zeroConfig{}
+		err = Apply(&loaded, &Options{ConfigFilename: path, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			contents, _ := os.ReadFile(path)
+			t.Fatalf("%s: %s\n%s", format, err, contents)
+		}
+		if !reflect.DeepEqual(loaded, zeroConfig{}) {
+			t.Errorf("%s: expected the zero values, got %+v", format, loaded)
+		}
+
+		// And the sample can be loaded unchanged
+		sample, err := GenerateConfigFile(&zeroConfig{}, format, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Apply(&zeroConfig{}, &Options{ConfigFilename: "sample." + format, cfgFileContents: sample, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Errorf("%s: %s\n%s", format, err, sample)
+		}
+	}
+}
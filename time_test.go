@@ -0,0 +1,158 @@
+package configape
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type timeConfig struct {
+	Timeout  time.Duration   `default:"30s" min:"1s"`
+	Retries  []time.Duration `default:"1s,5s"`
+	Backoff  map[string]time.Duration
+	Start    time.Time
+	Birthday *time.Time `layout:"2006-01-02"`
+	Limit    ByteSize   `default:"512KiB"`
+}
+
+func TestDurationAndTime(t *testing.T) {
+	cfg := timeConfig{}
+	err := Apply(&cfg, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout != 30*time.Second || !reflect.DeepEqual(cfg.Retries, []time.Duration{time.Second, 5 * time.Second}) || cfg.Limit != 512*KiB {
+		t.Errorf("expected the defaults, got %v %v %v", cfg.Timeout, cfg.Retries, cfg.Limit)
+	}
+
+	os.Setenv("CFG_TIMEOUT", "1m30s")
+	os.Setenv("CFG_START", "2024-03-01T10:00:00Z")
+	defer os.Unsetenv("CFG_TIMEOUT")
+	defer os.Unsetenv("CFG_START")
+	cfg = timeConfig{}
+	err = Apply(&cfg, &Options{DisableConfigFile: true, osArgs: []string{"cfgape", "--birthday", "2000-02-29", "--backoff", "a=2s", "--limit", "10MB"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Timeout != 90*time.Second || cfg.Backoff["a"] != 2*time.Second || cfg.Limit != 10*MB {
+		t.Errorf("expected the values from the environment and command line, got %v %v %v", cfg.Timeout, cfg.Backoff, cfg.Limit)
+	}
+	if !cfg.Start.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || cfg.Birthday == nil || !cfg.Birthday.Equal(time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the times to be parsed, got %v %v", cfg.Start, cfg.Birthday)
+	}
+
+	// Errors
+	err = Apply(&timeConfig{}, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--timeout", "30"}})
+	var parseErr *ErrParseValue
+	if !errors.As(err, &parseErr) || parseErr.Setting != "timeout" || !strings.Contains(err.Error(), "invalid duration value: 30") {
+		t.Errorf("expected a duration error, got: %v", err)
+	}
+	err = Apply(&timeConfig{}, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--birthday", "29/02/2000"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid time value: 29/02/2000 (expected 2006-01-02)") {
+		t.Errorf("expected a time error, got: %v", err)
+	}
+	err = Apply(&timeConfig{}, &Options{DisableConfigFile: true, DisableEnviornment: true, osArgs: []string{"cfgape", "--timeout", "10ms"}})
+	if err == nil || !strings.Contains(err.Error(), "timeout") {
+		t.Errorf("expected the min to apply to durations, got: %v", err)
+	}
+
+	_, err = structToSettings(reflect.TypeOf(struct {
+		Bad string `layout:"2006"`
+	}{}))
+	if err == nil {
+		t.Error("expected an error for a layout on a string")
+	}
+}
+
+func TestDurationAndTimeConfigFiles(t *testing.T) {
+	files := map[string]string{
+		"json": `{"timeout": "2m", "retries": ["1s", "2s"], "backoff": {"a": "3s"}, "start": "2024-03-01T10:00:00Z", "birthday": "2000-02-29", "limit": "1GiB"}`,
+		"yaml": "timeout: 2m\nretries: [1s, 2s]\nbackoff:\n  a: 3s\nstart: 2024-03-01T10:00:00Z\nbirthday: 2000-02-29\nlimit: 1GiB\n",
+		"toml": "timeout = \"2m\"\nretries = [\"1s\", \"2s\"]\nbackoff = { a = \"3s\" }\nstart = \"2024-03-01T10:00:00Z\"\nbirthday = \"2000-02-29\"\nlimit = \"1GiB\"\n",
+	}
+	for format, contents := range files {
+		cfg := timeConfig{}
+		err := Apply(&cfg, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if cfg.Timeout != 2*time.Minute || !reflect.DeepEqual(cfg.Retries, []time.Duration{time.Second, 2 * time.Second}) || cfg.Backoff["a"] != 3*time.Second || cfg.Limit != GiB {
+			t.Errorf("%s: expected the values from the file, got %v %v %v %v", format, cfg.Timeout, cfg.Retries, cfg.Backoff, cfg.Limit)
+		}
+		if !cfg.Start.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)) || cfg.Birthday == nil || !cfg.Birthday.Equal(time.Date(2000, 2, 29, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("%s: expected the times from the file, got %v %v", format, cfg.Start, cfg.Birthday)
+		}
+	}
+
+	err := Apply(&timeConfig{}, &Options{ConfigFilename: "test.json", cfgFileContents: `{"timeout": "soon"}`, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid duration value: soon") {
+		t.Errorf("expected a duration error, got: %v", err)
+	}
+
+	// A number isn't a duration, rather than being nanoseconds
+	numbers := map[string]string{
+		"json": "{\n  \"timeout\": 5\n}",
+		"yaml": "limit: 1KiB\ntimeout: 30\n",
+		"toml": "limit = 1024\ntimeout = 30\n",
+	}
+	for format, contents := range numbers {
+		err := Apply(&timeConfig{}, &Options{ConfigFilename: "test." + format, cfgFileContents: contents, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		var parseErr *ErrParseValue
+		if !errors.As(err, &parseErr) || parseErr.Setting != "timeout" || parseErr.Source.Line != 2 {
+			t.Errorf("%s: expected an error for the timeout on line 2, got: %v", format, err)
+		}
+		if err == nil || !strings.Contains(err.Error(), `expected a duration with a unit, eg "30s"`) {
+			t.Errorf("%s: expected a duration error, got: %v", format, err)
+		}
+	}
+
+	err = Apply(&timeConfig{}, &Options{ConfigFilename: "test.json", cfgFileContents: `{"retries": ["1s", 2]}`, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err == nil || !strings.Contains(err.Error(), `expected a duration with a unit, eg "30s", got 2`) {
+		t.Errorf("expected a duration error for the list, got: %v", err)
+	}
+
+	// Written as the strings they were read from
+	birthday := time.Date(1990, 7, 4, 0, 0, 0, 0, time.UTC)
+	cfg := timeConfig{Timeout: 90 * time.Second, Retries: []time.Duration{time.Second}, Birthday: &birthday, Limit: 3 * MB}
+	dir := t.TempDir()
+	for _, format := range []string{"json", "yaml", "toml"} {
+		path := filepath.Join(dir, "config."+format)
+		err := Save(&cfg, path, &Options{SaveSkipDefaults: true})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		contents, _ := os.ReadFile(path)
+		for _, want := range []string{`"1m30s"`, `"1990-07-04"`, `"3MB"`} {
+			if !strings.Contains(string(contents), want) {
+				t.Errorf("%s: expected %s in:\n%s", format, want, contents)
+			}
+		}
+		loaded := timeConfig{}
+		err = Apply(&loaded, &Options{ConfigFilename: path, DisableEnviornment: true, osArgs: []string{"cfgape"}})
+		if err != nil {
+			t.Fatalf("%s: %s", format, err)
+		}
+		if loaded.Timeout != cfg.Timeout || !loaded.Birthday.Equal(birthday) || loaded.Limit != cfg.Limit {
+			t.Errorf("%s: expected the saved values, got %v %v %v", format, loaded.Timeout, loaded.Birthday, loaded.Limit)
+		}
+	}
+}
+
+func TestDurationAndTimeSchema(t *testing.T) {
+	str, err := JSONSchema(&timeConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"default": "30s"`, `"format": "date-time"`, `"default": "512KiB"`} {
+		if !strings.Contains(str, want) {
+			t.Errorf("expected %s in the schema:\n%s", want, str)
+		}
+	}
+	if strings.Contains(str, `"minimum"`) {
+		t.Errorf("expected no minimum for a duration:\n%s", str)
+	}
+}
@@ -0,0 +1,168 @@
+package configape
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+)
+
+// The built in toml ConfigDecoder. The values are decoded by go-toml, and then the document is
+// walked again to find the order and position of the keys, which the decoded maps don't have.
+func decodeToml(fh io.Reader) (*ConfigNode, error) {
+	contents, err := io.ReadAll(fh)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	err = toml.Unmarshal(contents, &values)
+	var decodeErr *toml.DecodeError
+	if errors.As(err, &decodeErr) {
+		line, column := decodeErr.Position()
+		return nil, &ErrConfigSyntax{Line: line, Column: column, Err: errors.New(strings.TrimPrefix(decodeErr.Error(), "toml: "))}
+	}
+	keys := findTomlKeys(contents)
+	if err != nil {
+		// Keys and tables that are defined twice don't say where, so find the second one
+		syntaxErr := &ErrConfigSyntax{Err: errors.New(strings.TrimPrefix(err.Error(), "toml: "))}
+		if keys.duplicate != nil {
+			syntaxErr.Line, syntaxErr.Column = keys.duplicate.line, keys.duplicate.column
+		}
+		return nil, syntaxErr
+	}
+	root := &ConfigNode{}
+	tomlChildren(root, values, "", keys.positions)
+	return root, nil
+}
+
+// Where a key was first found in the document
+type tomlPosition struct {
+	line   int
+	column int
+	order  int
+}
+
+type tomlKeys struct {
+	parser    unstable.Parser
+	positions map[string]tomlPosition // By the dotted path of the key, eg "database.pool.size"
+	defined   map[string]bool         // The keys with values, and the [tables]
+	duplicate *tomlPosition           // The first key or table that was defined again
+}
+
+// Finds the position of every key in the document. The keys in arrays of tables aren't included,
+// as they are only ever part of a value.
+func findTomlKeys(contents []byte) *tomlKeys {
+	keys := &tomlKeys{positions: map[string]tomlPosition{}, defined: map[string]bool{}}
+	keys.parser.Reset(contents)
+	table, inArray := "", false
+	for keys.parser.NextExpression() {
+		expr := keys.parser.Expression()
+		switch expr.Kind {
+		case unstable.Table:
+			table, inArray = keys.define(keys.record("", expr.Key())), false
+		case unstable.ArrayTable:
+			keys.record("", expr.Key())
+			inArray = true
+		case unstable.KeyValue:
+			if !inArray {
+				keys.recordKeyValue(table, expr)
+			}
+		}
+	}
+	return keys
+}
+
+// Records the position of each part of a (possibly dotted) key, and returns its path and the
+// position of its last part
+func (k *tomlKeys) record(path string, key unstable.Iterator) (string, tomlPosition) {
+	var position tomlPosition
+	for key.Next() {
+		if path != "" {
+			path += "."
+		}
+		path += string(key.Node().Data)
+		shape := k.parser.Shape(key.Node().Raw)
+		position = tomlPosition{line: shape.Start.Line, column: shape.Start.Column, order: len(k.positions)}
+		if _, ok := k.positions[path]; !ok {
+			k.positions[path] = position
+		}
+	}
+	return path, position
+}
+
+// Marks the key, or table, as defined, and remembers where it was if it already was
+func (k *tomlKeys) define(path string, position tomlPosition) string {
+	if k.defined[path] && k.duplicate == nil {
+		k.duplicate = &position
+	}
+	k.defined[path] = true
+	return path
+}
+
+// Records the key of a key/value, and the keys of an inline table value
+func (k *tomlKeys) recordKeyValue(path string, expr *unstable.Node) {
+	path = k.define(k.record(path, expr.Key()))
+	if value := expr.Value(); value.Kind == unstable.InlineTable {
+		children := value.Children()
+		for children.Next() {
+			k.recordKeyValue(path, children.Node())
+		}
+	}
+}
+
+// Add the keys of the toml table as children of node, in the order they are in the document
+func tomlChildren(node *ConfigNode, table map[string]interface{}, path string, positions map[string]tomlPosition) {
+	node.Children = make([]*ConfigNode, 0, len(table))
+	for key, value := range table {
+		child := &ConfigNode{Key: key, Value: tomlValue(value)}
+		childPath := key
+		if path != "" {
+			childPath = path + "." + key
+		}
+		position := positions[childPath]
+		child.Line, child.Column = position.line, position.column
+		if sub, ok := value.(map[string]interface{}); ok {
+			tomlChildren(child, sub, childPath, positions)
+		}
+		node.Children = append(node.Children, child)
+	}
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		return tomlOrder(node.Children[i], path, positions) < tomlOrder(node.Children[j], path, positions)
+	})
+}
+
+func tomlOrder(node *ConfigNode, path string, positions map[string]tomlPosition) int {
+	if path != "" {
+		path += "."
+	}
+	if position, ok := positions[path+node.Key]; ok {
+		return position.order
+	}
+	return len(positions)
+}
+
+// go-toml decodes local dates and times as its own types, but they can only be a time.Time here,
+// so they are in the local timezone. Integers are already int64, and tables map[string]interface{}.
+func tomlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case toml.LocalDate:
+		return v.AsTime(time.Local)
+	case toml.LocalDateTime:
+		return v.AsTime(time.Local)
+	case toml.LocalTime:
+		return time.Date(0, 1, 1, v.Hour, v.Minute, v.Second, v.Nanosecond, time.Local)
+	case []interface{}:
+		for idx := range v {
+			v[idx] = tomlValue(v[idx])
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = tomlValue(v[key])
+		}
+	}
+	return value
+}
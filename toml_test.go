@@ -0,0 +1,250 @@
+package configape
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTomlParse(t *testing.T) {
+	doc := `
+# A comment
+title = "TOML \"test\"" # trailing comment
+literal = 'C:\Users\nodejs'
+int = 1_000
+hex = 0xDEAD_BEEF
+float = -3.5e2
+flag = true
+list = [ 1, 2,
+  3, ] # trailing comma
+multi = """
+one \
+   two"""
+dotted.key = "value"
+date = 1979-05-27T07:32:00Z
+inline = { a = 1, b = "two" }
+
+[database]
+host = "localhost"
+
+[database.pool]
+size = 10
+
+[[servers]]
+name = "alpha"
+
+[[servers]]
+name = "beta"
+`
+	root, err := decodeToml(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := map[string]interface{}{
+		"title":   `TOML "test"`,
+		"literal": `C:\Users\nodejs`,
+		"int":     int64(1000),
+		"hex":     int64(0xDEADBEEF),
+		"float":   -350.0,
+		"flag":    true,
+		"multi":   "one two",
+		"list":    []interface{}{int64(1), int64(2), int64(3)},
+		"dotted":  map[string]interface{}{"key": "value"},
+		"inline":  map[string]interface{}{"a": int64(1), "b": "two"},
+		"database": map[string]interface{}{
+			"host": "localhost",
+			"pool": map[string]interface{}{"size": int64(10)},
+		},
+	}
+	for key, expected := range checks {
+		node := tomlNode(root, key)
+		if node == nil {
+			t.Errorf("key %s not found", key)
+			continue
+		}
+		if !reflect.DeepEqual(node.Value, expected) {
+			t.Errorf("key %s was %#v, expected %#v", key, node.Value, expected)
+		}
+	}
+	servers, ok := tomlNode(root, "servers").Value.([]interface{})
+	if !ok || len(servers) != 2 || !reflect.DeepEqual(servers[1], map[string]interface{}{"name": "beta"}) {
+		t.Errorf("servers was %#v", tomlNode(root, "servers").Value)
+	}
+
+	// The keys are in the order, and have the position, they are in the document
+	keys := []string{}
+	for _, child := range root.Children {
+		keys = append(keys, child.Key)
+	}
+	expectedKeys := []string{"title", "literal", "int", "hex", "float", "flag", "list", "multi", "dotted", "date", "inline", "database", "servers"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("expected the keys in document order, got %v", keys)
+	}
+	positions := map[string][2]int{"title": {3, 1}, "dotted": {14, 1}, "inline": {16, 1}, "database": {18, 2}, "database.pool": {21, 11}, "database.pool.size": {22, 1}, "servers": {24, 3}}
+	for key, expected := range positions {
+		node := tomlNode(root, key)
+		if node == nil || node.Line != expected[0] || node.Column != expected[1] {
+			t.Errorf("expected %s at line %d, column %d, got %+v", key, expected[0], expected[1], node)
+		}
+	}
+	if node := tomlNode(root, "inline.b"); node == nil || node.Line != 16 || node.Column != 19 {
+		t.Errorf("expected the inline table key position, got %+v", node)
+	}
+}
+
+// Returns the node at the dotted path
+func tomlNode(node *ConfigNode, path string) *ConfigNode {
+	for _, key := range strings.Split(path, ".") {
+		var found *ConfigNode
+		for _, child := range node.Children {
+			if child.Key == key {
+				found = child
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		node = found
+	}
+	return node
+}
+
+func TestTomlParseErrors(t *testing.T) {
+	tests := []struct {
+		doc  string
+		line int
+	}{
+		{"foo = ", 1},
+		{"foo = \"bar", 1},
+		{"foo = 1\nfoo = 2", 2},
+		{"a = { b = 1, b = 2 }", 1},
+		{"[a]\n[a]", 2},
+		{"foo = 012", 1},
+		{"foo = 1 bar", 1},
+		{"foo = [1, 2", 1},
+		{"foo = 1.", 1},
+		{"foo = .5", 1},
+		{"foo = 1979-05-27T25:00:00", 1},
+	}
+	for _, test := range tests {
+		_, err := decodeToml(strings.NewReader(test.doc))
+		var syntaxErr *ErrConfigSyntax
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("expected a syntax error parsing %q, got: %v", test.doc, err)
+		} else if syntaxErr.Line != test.line {
+			t.Errorf("expected the error parsing %q to be on line %d, got: %s", test.doc, test.line, err)
+		}
+	}
+}
+
+func TestTomlDates(t *testing.T) {
+	tests := []struct {
+		doc      string
+		expected time.Time
+	}{
+		{"date = 1979-05-27T07:32:00Z", time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{"date = 1979-05-27t07:32:00z", time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC)},
+		{"date = 1979-05-27T00:32:00.5-07:00", time.Date(1979, 5, 27, 7, 32, 0, 500000000, time.UTC)},
+		{"date = 1979-05-27T07:32:00", time.Date(1979, 5, 27, 7, 32, 0, 0, time.Local)},
+		{"date = 1979-05-27 07:32:00.999", time.Date(1979, 5, 27, 7, 32, 0, 999000000, time.Local)},
+		{"date = 1979-05-27", time.Date(1979, 5, 27, 0, 0, 0, 0, time.Local)},
+		{"date = 07:32:00", time.Date(0, 1, 1, 7, 32, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		root, err := decodeToml(strings.NewReader(test.doc))
+		if err != nil {
+			t.Errorf("%s: %s", test.doc, err)
+			continue
+		}
+		date, ok := root.Children[0].Value.(time.Time)
+		if !ok || !date.Equal(test.expected) {
+			t.Errorf("%s: expected %v, got %#v", test.doc, test.expected, root.Children[0].Value)
+		}
+	}
+
+	// And local dates can be read into a time
+	cfg := struct {
+		Start    time.Time
+		Birthday time.Time `layout:"2006-01-02"`
+	}{}
+	err := Apply(&cfg, &Options{ConfigFilename: "test.toml", cfgFileContents: "start = 2024-03-01T10:00:00\nbirthday = 2000-02-29\n", DisableEnviornment: true, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Start.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)) || cfg.Birthday.Format("2006-01-02") != "2000-02-29" {
+		t.Errorf("expected the local dates from the file, got %v %v", cfg.Start, cfg.Birthday)
+	}
+}
+
+func TestTomlConfigFile(t *testing.T) {
+	cfg := struct {
+		Foo      string
+		Number   int
+		List     []string
+		Custom   customUnmarshalerType
+		Database struct {
+			Host string
+			Port int
+		}
+	}{}
+	fileContents := `
+foo = "bar"
+number = 42
+list = ["a", "b"]
+custom = "wibble"
+
+[database]
+host = "dbhost"
+port = 5432
+`
+	c := cfgApe{options: Options{cfgFileContents: fileContents}}
+	c.cfg = &cfg
+	if err := c.parseStructIntoSettings(); err != nil {
+		t.Fatal(err)
+	}
+	err := c.decodeConfigFile("fake.toml", ConfigDecoderFunc(decodeToml), bytes.NewBufferString(fileContents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := setValues(&cfg, c.settings); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Foo != "bar" {
+		t.Error("foo was not bar")
+	}
+	if cfg.Number != 42 {
+		t.Error("number was not 42")
+	}
+	if len(cfg.List) != 2 || cfg.List[1] != "b" {
+		t.Errorf("list was not [a b]: %v", cfg.List)
+	}
+	if cfg.Custom.Foo != "\"wibble\"" {
+		t.Errorf("custom was not \"wibble\": %s", cfg.Custom.Foo)
+	}
+	if cfg.Database.Host != "dbhost" || cfg.Database.Port != 5432 {
+		t.Errorf("database was not dbhost:5432: %+v", cfg.Database)
+	}
+
+	// Unknown keys and bad types report where they were found
+	c.options.AllowUnknownConfigFileKeys = false
+	err = c.decodeConfigFile("fake.toml", ConfigDecoderFunc(decodeToml), bytes.NewBufferString("foo = 1\nwibble = 2\n"))
+	if err == nil {
+		t.Error("expected error")
+	} else if !strings.Contains(err.Error(), "line 1, column 1") {
+		t.Errorf("expected error to contain line and column: %s", err)
+	}
+	err = c.decodeConfigFile("fake.toml", ConfigDecoderFunc(decodeToml), bytes.NewBufferString("wibble = 2\n"))
+	if err == nil {
+		t.Error("expected error")
+	} else if !strings.Contains(err.Error(), "wibble at line 1, column 1") {
+		t.Errorf("expected error to contain wibble and position: %s", err)
+	}
+	c.options.AllowUnknownConfigFileKeys = true
+	err = c.decodeConfigFile("fake.toml", ConfigDecoderFunc(decodeToml), bytes.NewBufferString("wibble = 2\n"))
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,346 @@
+package configape
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator can be implemented by the config struct, or any of its subsection structs, to check
+// rules that can't be written as tags (eg TLSKey is required when TLSCert is set). Validate is
+// called after all the values have been set, subsections before the structs that contain them.
+type Validator interface {
+	Validate() error
+}
+
+// The constraints from the validation tags (min, max, oneof, pattern, minlen, maxlen, len, schemes)
+type cfgValidation struct {
+	min      *reflect.Value // The parsed min tag
+	max      *reflect.Value // The parsed max tag
+	minTag   string
+	maxTag   string
+	oneOf    []string
+	pattern  *regexp.Regexp
+	schemes  []string // The schemes a URL can have
+	minLen   *int
+	maxLen   *int
+	exactLen *int
+}
+
+// Returns the type that min, max, oneof and pattern apply to. For lists that is each element.
+func validationElemType(valType reflect.Type) reflect.Type {
+	if valType.Kind() == reflect.Ptr {
+		valType = valType.Elem()
+	}
+	if valType.Kind() == reflect.Slice {
+		valType = valType.Elem()
+		if valType.Kind() == reflect.Ptr {
+			valType = valType.Elem()
+		}
+	}
+	return valType
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// Read the validation tags on the field into the setting.
+func parseValidationTags(field reflect.StructField, setting *cfgSetting) error {
+	v := cfgValidation{}
+	elemType := validationElemType(field.Type)
+
+	for _, tag := range []string{"min", "max"} {
+		str := field.Tag.Get(tag)
+		if str == "" {
+			continue
+		}
+		if !isNumberKind(elemType.Kind()) {
+			return fmt.Errorf("struct field %s, %s can only be used on numbers", field.Name, tag)
+		}
+		value, err := strToType(elemType, str)
+		if err != nil {
+			return fmt.Errorf("struct field %s, invalid %s: %s", field.Name, tag, err)
+		}
+		if tag == "min" {
+			v.min, v.minTag = &value, str
+		} else {
+			v.max, v.maxTag = &value, str
+		}
+	}
+	if oneOf := field.Tag.Get("oneof"); oneOf != "" {
+		for _, option := range strings.Split(oneOf, ",") {
+			v.oneOf = append(v.oneOf, strings.TrimSpace(option))
+		}
+	}
+	if pattern := field.Tag.Get("pattern"); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("struct field %s, invalid pattern: %s", field.Name, err)
+		}
+		v.pattern = re
+	}
+	if schemes := field.Tag.Get("schemes"); schemes != "" {
+		if elemType != reflect.TypeOf(url.URL{}) {
+			return fmt.Errorf("struct field %s, schemes can only be used on URLs", field.Name)
+		}
+		for _, scheme := range strings.Split(schemes, ",") {
+			v.schemes = append(v.schemes, strings.ToLower(strings.TrimSpace(scheme)))
+		}
+	}
+	for tag, dest := range map[string]**int{"minlen": &v.minLen, "maxlen": &v.maxLen, "len": &v.exactLen} {
+		str := field.Tag.Get(tag)
+		if str == "" {
+			continue
+		}
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 0 {
+			return fmt.Errorf("struct field %s, invalid %s: %s", field.Name, tag, str)
+		}
+		switch field.Type.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map:
+		case reflect.Ptr:
+			if kind := field.Type.Elem().Kind(); kind != reflect.String && kind != reflect.Slice {
+				return fmt.Errorf("struct field %s, %s can only be used on strings and lists", field.Name, tag)
+			}
+		default:
+			return fmt.Errorf("struct field %s, %s can only be used on strings and lists", field.Name, tag)
+		}
+		*dest = &n
+	}
+	setting.validation = v
+	return nil
+}
+
+// Describes the constraints for the help output, eg "(min: 1, max: 10)"
+func (s *cfgSetting) constraintsHelp() string {
+	v := s.validation
+	var parts []string
+	if v.min != nil {
+		parts = append(parts, fmt.Sprintf("min: %s", v.minTag))
+	}
+	if v.max != nil {
+		parts = append(parts, fmt.Sprintf("max: %s", v.maxTag))
+	}
+	if v.exactLen != nil {
+		parts = append(parts, fmt.Sprintf("length: %d", *v.exactLen))
+	}
+	if v.minLen != nil {
+		parts = append(parts, fmt.Sprintf("min length: %d", *v.minLen))
+	}
+	if v.maxLen != nil {
+		parts = append(parts, fmt.Sprintf("max length: %d", *v.maxLen))
+	}
+	if len(v.oneOf) > 0 {
+		parts = append(parts, fmt.Sprintf("one of: %s", strings.Join(v.oneOf, ", ")))
+	}
+	if v.pattern != nil {
+		parts = append(parts, fmt.Sprintf("pattern: %s", v.pattern))
+	}
+	if len(v.schemes) > 0 {
+		parts = append(parts, fmt.Sprintf("schemes: %s", strings.Join(v.schemes, ", ")))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("(%s)", strings.Join(parts, ", "))
+}
+
+// Checks all of the settings that have a value against their validation tags, returning a
+// MultiError of all of those that fail.
+func (s cfgSettings) CheckValid() error {
+	errs := &MultiError{}
+	s.checkValid(errs)
+	return errs.errorOrNil()
+}
+
+func (s cfgSettings) checkValid(errs *MultiError) {
+	for i := 0; i < len(s); i++ {
+		setting := &s[i]
+		if setting.fieldType == fieldTypeSubsection {
+			setting.subsection.checkValid(errs)
+			continue
+		}
+		if setting.fieldType == fieldTypeCommand {
+			if setting.valueSet {
+				setting.subsection.checkValid(errs)
+			}
+			continue
+		}
+		if !setting.valueSet {
+			continue
+		}
+		err := setting.validate()
+		if err != nil {
+			errs.add(&ErrInvalidValue{Setting: setting.path, Source: setting.whereSet, Err: err})
+		}
+	}
+}
+
+// Check the value of the setting against the validation tags
+func (s *cfgSetting) validate() error {
+	v := s.validation
+	value := s.reflectValue
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return nil
+	}
+
+	if v.exactLen != nil || v.minLen != nil || v.maxLen != nil {
+		length := 0
+		if value.Kind() == reflect.String {
+			length = utf8.RuneCountInString(value.String())
+		} else {
+			length = value.Len()
+		}
+		if v.exactLen != nil && length != *v.exactLen {
+			return fmt.Errorf("length must be %d", *v.exactLen)
+		}
+		if v.minLen != nil && length < *v.minLen {
+			return fmt.Errorf("length must be at least %d", *v.minLen)
+		}
+		if v.maxLen != nil && length > *v.maxLen {
+			return fmt.Errorf("length must be at most %d", *v.maxLen)
+		}
+	}
+
+	if value.Kind() == reflect.Slice {
+		for idx := 0; idx < value.Len(); idx++ {
+			err := s.validateElem(value.Index(idx))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return s.validateElem(value)
+}
+
+// Check a single value against min, max, oneof and pattern
+func (s *cfgSetting) validateElem(value reflect.Value) error {
+	v := s.validation
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if v.min != nil && compareNumbers(value, *v.min) < 0 {
+		return fmt.Errorf("must be at least %s", v.minTag)
+	}
+	if v.max != nil && compareNumbers(value, *v.max) > 0 {
+		return fmt.Errorf("must be at most %s", v.maxTag)
+	}
+	if u, ok := value.Interface().(url.URL); ok && len(v.schemes) > 0 && !value.IsZero() {
+		found := false
+		for _, scheme := range v.schemes {
+			if strings.EqualFold(u.Scheme, scheme) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("scheme must be one of: %s", strings.Join(v.schemes, ", "))
+		}
+	}
+	if len(v.oneOf) > 0 || v.pattern != nil {
+		str := fmt.Sprint(value.Interface())
+		if isParsedType(value.Type()) {
+			// eg the URL, rather than its fields
+			str = parsedTypeText(value)
+		}
+		if len(v.oneOf) > 0 {
+			found := false
+			for _, option := range v.oneOf {
+				if str == option {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return fmt.Errorf("must be one of: %s", strings.Join(v.oneOf, ", "))
+			}
+		}
+		if v.pattern != nil && !v.pattern.MatchString(str) {
+			return fmt.Errorf("must match the pattern %s", v.pattern)
+		}
+	}
+	return nil
+}
+
+// Compares two numbers of the same kind, returning -1, 0 or 1
+func compareNumbers(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch {
+		case a.Int() < b.Int():
+			return -1
+		case a.Int() > b.Int():
+			return 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case a.Uint() < b.Uint():
+			return -1
+		case a.Uint() > b.Uint():
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case a.Float() < b.Float():
+			return -1
+		case a.Float() > b.Float():
+			return 1
+		}
+	}
+	return 0
+}
+
+// Call Validate on the struct and all of its subsections and selected commands that implement
+// Validator, innermost first.
+// Errors from subsections are prefixed with the path of the subsection, eg "database: ...".
+func runValidators(value reflect.Value, settings cfgSettings, path string, errs *MultiError) {
+	for _, setting := range settings {
+		subPath := setting.configKey()
+		field := value.Field(setting.idx)
+		if setting.fieldType == fieldTypeCommand {
+			if !setting.valueSet {
+				continue
+			}
+			subPath = setting.commandName()
+			if field.Kind() == reflect.Ptr {
+				field = field.Elem()
+			}
+		} else if setting.fieldType != fieldTypeSubsection {
+			continue
+		}
+		if path != "" {
+			subPath = path + "." + subPath
+		}
+		runValidators(field, setting.subsection, subPath, errs)
+	}
+	validator, ok := value.Addr().Interface().(Validator)
+	if !ok {
+		return
+	}
+	err := validator.Validate()
+	if err != nil && path != "" {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	errs.add(err)
+}
@@ -0,0 +1,210 @@
+package configape
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type validationConfig struct {
+	Port     int      `min:"1" max:"65535" default:"8080"`
+	Ratio    float64  `min:"0" max:"1"`
+	Level    string   `oneof:"debug,info,warn" default:"info"`
+	Name     string   `pattern:"^[a-z]+$" minlen:"2" maxlen:"8"`
+	Tags     []string `len:"2" oneof:"a,b,c"`
+	Sizes    []uint   `max:"10"`
+	Database struct {
+		Pool *int `min:"1"`
+	}
+}
+
+func TestValidation(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--port=443", "--ratio=0.5", "--level=warn", "--name=abc", "--tags=a", "--tags=c", "--sizes=10"}, ""},
+		{[]string{"--port=0"}, "invalid value for port from command line --port: must be at least 1"},
+		{[]string{"--port", "70000"}, "invalid value for port from command line --port: must be at most 65535"},
+		{[]string{"--ratio=1.5"}, "ratio from command line --ratio: must be at most 1"},
+		{[]string{"--level=trace"}, "level from command line --level: must be one of: debug, info, warn"},
+		{[]string{"--name=ABC"}, "name from command line --name: must match the pattern ^[a-z]+$"},
+		{[]string{"--name=a"}, "length must be at least 2"},
+		{[]string{"--name=abcdefghi"}, "length must be at most 8"},
+		{[]string{"--tags=a"}, "tags from command line --tags: length must be 2"},
+		{[]string{"--tags=a", "--tags=d"}, "must be one of: a, b, c"},
+		{[]string{"--sizes=1", "--sizes=11"}, "must be at most 10"},
+		{[]string{"--database-pool=0"}, "invalid value for database.pool from command line --database-pool: must be at least 1"},
+	}
+	for _, test := range tests {
+		cfg := validationConfig{}
+		err := Apply(&cfg, &Options{
+			DisableEnviornment:           true,
+			DisableConfigFile:            true,
+			DisableHelpOnMissingRequired: true,
+			osArgs:                       append([]string{"cfgape"}, test.args...),
+		})
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %s", test.args, err)
+			}
+		} else if err == nil {
+			t.Errorf("%v: expected error", test.args)
+		} else if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: expected error to contain %q, got: %s", test.args, test.err, err)
+		}
+	}
+
+	// And are typed errors
+	err := Apply(&validationConfig{}, &Options{DisableEnviornment: true, DisableConfigFile: true, osArgs: []string{"cfgape", "--database-pool=0"}})
+	var invalid *ErrInvalidValue
+	if !errors.As(err, &invalid) || invalid.Setting != "database.pool" || invalid.Source.Name != "--database-pool" || invalid.Err.Error() != "must be at least 1" {
+		t.Errorf("expected ErrInvalidValue for database.pool, got: %#v", invalid)
+	}
+
+	// Defaults are validated too
+	cfg := struct {
+		Level string `oneof:"debug,info" default:"trace"`
+	}{}
+	err = Apply(&cfg, &Options{DisableEnviornment: true, DisableConfigFile: true, osArgs: []string{"cfgape"}})
+	if err == nil || !strings.Contains(err.Error(), "level from default value: must be one of: debug, info") {
+		t.Errorf("expected default to fail validation: %v", err)
+	}
+}
+
+func TestValidationTags(t *testing.T) {
+	bad := []interface{}{
+		struct {
+			Foo string `min:"1"`
+		}{},
+		struct {
+			Foo int `max:"ten"`
+		}{},
+		struct {
+			Foo string `pattern:"["`
+		}{},
+		struct {
+			Foo int `maxlen:"2"`
+		}{},
+		struct {
+			Foo string `len:"-1"`
+		}{},
+	}
+	for _, cfg := range bad {
+		_, err := structToSettings(reflect.TypeOf(cfg))
+		if err == nil {
+			t.Errorf("expected error for %T", cfg)
+		}
+	}
+}
+
+func TestValidationHelp(t *testing.T) {
+	help, err := Help(&validationConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"--port <Port> (default: 8080) (min: 1, max: 65535)",
+		"--level <Level> (default: info) (one of: debug, info, warn)",
+		"--name <Name> (min length: 2, max length: 8, pattern: ^[a-z]+$)",
+		"--tags <Tags> (length: 2, one of: a, b, c)",
+	} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("help did not contain %q:\n%s", expected, help)
+		}
+	}
+}
+
+var errPoolSize = errors.New("min_conns must not be more than max_conns")
+
+type poolConfig struct {
+	MinConns int
+	MaxConns int
+}
+
+func (p *poolConfig) Validate() error {
+	if p.MinConns > p.MaxConns {
+		return errPoolSize
+	}
+	return nil
+}
+
+type tlsConfig struct {
+	Cert string
+	Key  string
+}
+
+func (t tlsConfig) Validate() error {
+	if t.Cert != "" && t.Key == "" {
+		return errors.New("key is required when cert is set")
+	}
+	return nil
+}
+
+type validatorConfig struct {
+	Name     string
+	TLS      tlsConfig `name:"tls"`
+	Database struct {
+		Pool poolConfig `name:"pool"`
+	}
+}
+
+func (v *validatorConfig) Validate() error {
+	if v.Name == "bad" {
+		return errors.New("name must not be bad")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		args []string
+		err  string
+	}{
+		{[]string{"--database-pool-min-conns=1", "--database-pool-max-conns=2", "--tls-cert=a", "--tls-key=b"}, ""},
+		{[]string{"--database-pool-min-conns=3", "--database-pool-max-conns=2"}, "database.pool: min_conns must not be more than max_conns"},
+		{[]string{"--tls-cert=a"}, "tls: key is required when cert is set"},
+		{[]string{"--name=bad"}, "name must not be bad"},
+		// Subsections are validated first
+		{[]string{"--name=bad", "--tls-cert=a"}, "tls: key is required when cert is set\nname must not be bad"},
+	}
+	for _, test := range tests {
+		cfg := validatorConfig{}
+		err := Apply(&cfg, &Options{
+			DisableEnviornment: true,
+			DisableConfigFile:  true,
+			osArgs:             append([]string{"cfgape"}, test.args...),
+		})
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error: %s", test.args, err)
+			}
+		} else if err == nil {
+			t.Errorf("%v: expected error", test.args)
+		} else if err.Error() != test.err {
+			t.Errorf("%v: expected error %q, got: %s", test.args, test.err, err)
+		}
+	}
+
+	cfg := validatorConfig{}
+	err := Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--database-pool-min-conns=3"},
+	})
+	if !errors.Is(err, errPoolSize) {
+		t.Errorf("expected the error to wrap the validator error: %v", err)
+	}
+
+	// The struct is left as it was when it fails
+	cfg = validatorConfig{Name: "before"}
+	err = Apply(&cfg, &Options{
+		DisableEnviornment: true,
+		DisableConfigFile:  true,
+		osArgs:             []string{"cfgape", "--name=bad", "--tls-cert=a", "--tls-key=b"},
+	})
+	if err == nil || !reflect.DeepEqual(cfg, validatorConfig{Name: "before"}) {
+		t.Errorf("expected the struct to be unchanged by a failed Validate, got %+v: %v", cfg, err)
+	}
+}
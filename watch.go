@@ -0,0 +1,268 @@
+package configape
+
+import (
+	"crypto/sha256"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// A Watcher reloads the configuration when any of the config files change, or the program gets
+// a SIGHUP. The files, environment and command line are all applied again to a new struct, which
+// is checked before it replaces the current one. The struct given to Watch is never changed after
+// the first time, as that would race with the code reading it, use Current or OnChange instead,
+// or give Watch a *Live[T].
+type Watcher struct {
+	options  Options
+	cfgType  reflect.Type
+	files    []string
+	states   map[string]watchState // The config files as they were when they were last read
+	pending  map[string]watchState // The files that have changed since, as they were last checked
+	reload   sync.Mutex            // Only one reload at a time, also protects states and pending
+	mutex    sync.Mutex            // Protects current and the callbacks
+	current  interface{}
+	live     liveConfig // Set if Watch was given a *Live[T]
+	settings cfgSettings
+	onChange []func(cfg interface{}, changed []string)
+	onError  []func(err error)
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// What a config file was like the last time it was checked.
+type watchState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+func (s watchState) equal(other watchState) bool {
+	return s.exists == other.exists && s.modTime.Equal(other.modTime) && s.size == other.size && s.hash == other.hash
+}
+
+// Watch applies the configuration to cfg like Apply, and then watches for changes to it until
+// Close is called. The config files are checked every Options.WatchInterval, and a changed file
+// is only reloaded once it is the same on two checks in a row, so that a file that is still being
+// written isn't used.
+func Watch(cfg interface{}, options *Options) (*Watcher, error) {
+	if options == nil {
+		options = &Options{}
+	}
+	c := cfgApe{watching: true}
+	err := c.Apply(cfg, options)
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{
+		options:  *options,
+		files:    c.configFiles,
+		states:   c.fileStates,
+		pending:  map[string]watchState{},
+		current:  cfg,
+		settings: c.settings,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if live, ok := cfg.(liveConfig); ok {
+		w.live = live
+		w.current = live.current()
+	}
+	w.cfgType = reflect.TypeOf(w.current).Elem()
+	// Reloading must never print the help or exit
+	w.options.DisableHelpOnMissingRequired = true
+	go w.run()
+	return w, nil
+}
+
+// OnChange registers a function that is called with the new config struct (a pointer of the
+// same type given to Watch) and the paths of the settings that changed, eg database.host.
+func (w *Watcher) OnChange(fn func(cfg interface{}, changed []string)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onChange = append(w.onChange, fn)
+}
+
+// OnError registers a function that is called when the new configuration can't be used, the
+// current configuration stays in place.
+func (w *Watcher) OnError(fn func(err error)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onError = append(w.onError, fn)
+}
+
+// Current returns the current config struct, a pointer of the same type given to Watch. It
+// mustn't be changed, as it is shared.
+func (w *Watcher) Current() interface{} {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.current
+}
+
+// Close stops watching for changes.
+func (w *Watcher) Close() {
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	<-w.done
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	interval := w.options.WatchInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	signals := make(chan os.Signal, 1)
+	if len(reloadSignals) > 0 {
+		signal.Notify(signals, reloadSignals...)
+		defer signal.Stop(signals)
+	}
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if w.checkFiles() {
+				w.Reload()
+			}
+		case <-signals:
+			w.Reload()
+		}
+	}
+}
+
+// Checks if any of the config files have changed since they were last read, and are the same as
+// they were at the last check. The contents are only compared when the modification time or size
+// is different.
+func (w *Watcher) checkFiles() bool {
+	w.reload.Lock()
+	defer w.reload.Unlock()
+	changed := false
+	for _, file := range w.files {
+		old := w.states[file]
+		state, ok := readWatchState(file, old)
+		if !ok {
+			// Perhaps it is being replaced, try again next time
+			delete(w.pending, file)
+			continue
+		}
+		if state.equal(old) {
+			delete(w.pending, file)
+			continue
+		}
+		if pending, ok := w.pending[file]; !ok || !state.equal(pending) {
+			// It may still be being written
+			w.pending[file] = state
+			continue
+		}
+		delete(w.pending, file)
+		if state.exists != old.exists || state.hash != old.hash {
+			changed = true
+		}
+		w.states[file] = state
+	}
+	return changed
+}
+
+// The state of each of the config files.
+func readWatchStates(files []string) map[string]watchState {
+	states := map[string]watchState{}
+	for _, file := range files {
+		states[file], _ = readWatchState(file, watchState{})
+	}
+	return states
+}
+
+// The state of the config file now, the contents are only hashed when the modification time or
+// size is different from old, otherwise it has the hash from old. ok is false if the file exists
+// but can't be read.
+func readWatchState(file string, old watchState) (state watchState, ok bool) {
+	path, ok := expandConfigPath(file)
+	if !ok {
+		return watchState{}, true
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return watchState{}, true
+	}
+	state = watchState{exists: true, modTime: info.ModTime(), size: info.Size()}
+	if old.exists && state.modTime.Equal(old.modTime) && state.size == old.size {
+		state.hash = old.hash
+		return state, true
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return state, false
+	}
+	state.hash = sha256.Sum256(contents)
+	return state, true
+}
+
+// Reload applies the configuration again now, and if it is valid and different calls the
+// OnChange functions. If it isn't valid, the OnError functions are called and the error is
+// returned.
+func (w *Watcher) Reload() error {
+	w.reload.Lock()
+	defer w.reload.Unlock()
+
+	cfg := reflect.New(w.cfgType)
+	c := cfgApe{reloading: true, watching: true}
+	err := c.Apply(cfg.Interface(), &w.options)
+	if c.fileStates != nil {
+		w.states = c.fileStates
+		w.pending = map[string]watchState{}
+	}
+	w.mutex.Lock()
+	if err != nil {
+		onError := w.onError
+		w.mutex.Unlock()
+		for _, fn := range onError {
+			fn(err)
+		}
+		return err
+	}
+	changed := changedSettings(w.settings, reflect.ValueOf(w.current).Elem(), cfg.Elem())
+	if len(changed) == 0 {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.current = cfg.Interface()
+	onChange := w.onChange
+	w.mutex.Unlock()
+	if w.live != nil {
+		w.live.store(cfg.Interface(), changed)
+	}
+	for _, fn := range onChange {
+		fn(cfg.Interface(), changed)
+	}
+	return nil
+}
+
+// The paths of the settings that are different between the structs. Commands come from the
+// command line, so can't change.
+func changedSettings(settings cfgSettings, old reflect.Value, new reflect.Value) []string {
+	var changed []string
+	for i := 0; i < len(settings); i++ {
+		setting := &settings[i]
+		if setting.isCommand() {
+			continue
+		}
+		oldField, newField := old.Field(setting.idx), new.Field(setting.idx)
+		if setting.fieldType == fieldTypeSubsection {
+			changed = append(changed, changedSettings(setting.subsection, oldField, newField)...)
+			continue
+		}
+		if !reflect.DeepEqual(oldField.Interface(), newField.Interface()) {
+			changed = append(changed, setting.path)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package configape
+
+import "os"
+
+// Only unix has SIGHUP, so elsewhere Watch only reloads when the config files change.
+var reloadSignals []os.Signal
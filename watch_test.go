@@ -0,0 +1,161 @@
+package configape
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type watchConfig struct {
+	Port     int `default:"80" max:"1000"`
+	Database struct {
+		Host string `default:"localhost"`
+	}
+	Name string
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("port: 1\n"), 0644)
+
+	cfg := watchConfig{}
+	w, err := Watch(&cfg, &Options{ConfigFilename: path, WatchInterval: 10 * time.Millisecond, osArgs: []string{"cfgape", "--name", "bob"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if cfg.Port != 1 || w.Current() != &cfg {
+		t.Fatalf("expected the initial config, got %+v", cfg)
+	}
+	type change struct {
+		cfg     *watchConfig
+		changed []string
+	}
+	changes := make(chan change, 10)
+	errs := make(chan error, 10)
+	w.OnChange(func(cfg interface{}, changed []string) {
+		changes <- change{cfg.(*watchConfig), changed}
+	})
+	w.OnError(func(err error) {
+		errs <- err
+	})
+
+	// A change to the file is picked up
+	os.WriteFile(path, []byte("port: 2\ndatabase:\n  host: db\n"), 0644)
+	select {
+	case c := <-changes:
+		if c.cfg.Port != 2 || c.cfg.Database.Host != "db" || c.cfg.Name != "bob" {
+			t.Errorf("expected the new config, got %+v", c.cfg)
+		}
+		if !reflect.DeepEqual(c.changed, []string{"port", "database.host"}) {
+			t.Errorf("expected port and database.host to change, got %v", c.changed)
+		}
+		if w.Current() != c.cfg {
+			t.Error("expected the new config to be current")
+		}
+	case err := <-errs:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the change")
+	}
+	// The original struct isn't touched
+	if cfg.Port != 1 {
+		t.Errorf("expected the original config to be unchanged, got %+v", cfg)
+	}
+
+	// An invalid config is an error, and the current config stays
+	current := w.Current()
+	os.WriteFile(path, []byte("port: 2000\n"), 0644)
+	select {
+	case <-changes:
+		t.Fatal("expected no change for an invalid config")
+	case <-errs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the error")
+	}
+	if w.Current() != current {
+		t.Error("expected the current config to stay")
+	}
+}
+
+func TestWatchReload(t *testing.T) {
+	cfg := watchConfig{}
+	w, err := Watch(&cfg, &Options{DisableConfigFile: true, WatchInterval: time.Hour, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	var changed []string
+	w.OnChange(func(cfg interface{}, c []string) {
+		changed = c
+	})
+	// Nothing has changed
+	if err := w.Reload(); err != nil || changed != nil {
+		t.Errorf("expected no change, got %v %v", err, changed)
+	}
+	// The environment is read again too
+	os.Setenv("CFG_PORT", "8")
+	defer os.Unsetenv("CFG_PORT")
+	if err := w.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"port"}) || w.Current().(*watchConfig).Port != 8 {
+		t.Errorf("expected port to change to 8, got %v %+v", changed, w.Current())
+	}
+}
+
+func TestWatchPartialWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("port: 1\n"), 0644)
+	cfg := watchConfig{}
+	w, err := Watch(&cfg, &Options{ConfigFilename: path, WatchInterval: time.Hour, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if w.checkFiles() {
+		t.Error("expected the file to be unchanged")
+	}
+	// The file is truncated, and then written, it is only reloaded once it stays the same
+	os.WriteFile(path, []byte{}, 0644)
+	if w.checkFiles() {
+		t.Error("expected the empty file not to be reloaded")
+	}
+	os.WriteFile(path, []byte("port: 22\n"), 0644)
+	if w.checkFiles() {
+		t.Error("expected the file not to be reloaded until it is the same twice")
+	}
+	if !w.checkFiles() {
+		t.Error("expected the file to be reloaded")
+	}
+	if w.checkFiles() {
+		t.Error("expected the file to be reloaded once")
+	}
+}
+
+func TestWatchChangeWhileReading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("port: 1\n"), 0644)
+	// The file changes after it has been read the first time
+	decoder := ConfigDecoderFunc(func(r io.Reader) (*ConfigNode, error) {
+		node, err := decodeYaml(r)
+		os.WriteFile(path, []byte("port: 2\n"), 0644)
+		return node, err
+	})
+	cfg := watchConfig{}
+	w, err := Watch(&cfg, &Options{ConfigFilename: path, WatchInterval: time.Hour, Decoders: map[string]ConfigDecoder{"yaml": decoder}, osArgs: []string{"cfgape"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if cfg.Port != 1 {
+		t.Fatalf("expected port 1, got %d", cfg.Port)
+	}
+	w.checkFiles()
+	if !w.checkFiles() {
+		t.Error("expected the change while the file was read to be noticed")
+	}
+}
@@ -0,0 +1,11 @@
+//go:build unix
+
+package configape
+
+import (
+	"os"
+	"syscall"
+)
+
+// The signals that make Watch reload the configuration.
+var reloadSignals = []os.Signal{syscall.SIGHUP}
@@ -1,58 +1,102 @@
 package configape
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"reflect"
+	"regexp"
+	"strconv"
 
 	"gopkg.in/yaml.v3"
 )
 
-func (c *cfgApe) parseYamlConfigFile(cfgFile string, fh io.Reader) error {
-	yamlCfg := make(map[string]yaml.Node)
+// The built in yaml ConfigDecoder.
+func decodeYaml(fh io.Reader) (*ConfigNode, error) {
+	var document yaml.Node
 	decoder := yaml.NewDecoder(fh)
-	err := decoder.Decode(&yamlCfg)
-	if err != nil {
-		return fmt.Errorf("error parsing config file %s: %s", cfgFile, err)
+	err := decoder.Decode(&document)
+	if errors.Is(err, io.EOF) {
+		// An empty file has nothing in it, which is fine
+		return &ConfigNode{Children: []*ConfigNode{}}, nil
 	}
-	err = c.parseYamlMap(c.settings, yamlCfg)
 	if err != nil {
-		return fmt.Errorf("error parsing config file %s: %s", cfgFile, err)
+		return nil, yamlSyntaxError(err)
+	}
+	if len(document.Content) == 0 {
+		return &ConfigNode{Children: []*ConfigNode{}}, nil
+	}
+	mapping := resolveYamlAlias(document.Content[0])
+	if mapping.Kind != yaml.MappingNode {
+		return nil, &ErrConfigSyntax{Line: mapping.Line, Column: mapping.Column, Err: errors.New("expected a mapping")}
+	}
+	root := &ConfigNode{}
+	yamlChildren(root, mapping)
+	return root, nil
+}
+
+var yamlLineError = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// The yaml errors have the line number in the message (eg "yaml: line 3: did not find
+// expected key"), pull it out so the error has the line like the other formats.
+func yamlSyntaxError(err error) error {
+	match := yamlLineError.FindStringSubmatch(err.Error())
+	if match == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(match[1])
+	return &ErrConfigSyntax{Line: line, Err: errors.New(match[2])}
+}
+
+func resolveYamlAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+	return node
+}
+
+// Add the keys of the yaml mapping as children of node
+func yamlChildren(node *ConfigNode, mapping *yaml.Node) {
+	node.Children = []*ConfigNode{}
+	// Mappings have the key and value alternating in the content.
+	for idx := 0; idx+1 < len(mapping.Content); idx += 2 {
+		key := mapping.Content[idx]
+		value := resolveYamlAlias(mapping.Content[idx+1])
+		child := &ConfigNode{
+			Key:    key.Value,
+			Line:   key.Line,
+			Column: key.Column,
+			Decode: value.Decode,
+		}
+		var plain interface{}
+		if value.Decode(&plain) == nil {
+			child.Value = yamlValue(plain)
+		}
+		if value.Kind == yaml.MappingNode {
+			yamlChildren(child, value)
+		}
+		node.Children = append(node.Children, child)
 	}
-	return nil
 }
 
-func (c *cfgApe) parseYamlMap(settings cfgSettings, m map[string]yaml.Node) error {
-	for key, value := range m {
-		setting := settings.Find(key, "config")
-		if setting == nil {
-			if c.options.AllowUnknownConfigFileKeys {
-				continue
-			}
-			return fmt.Errorf("unknown setting in config file: %s", key)
+// yaml decodes integers as int and nested maps with interface keys, so tidy them up.
+func yamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case []interface{}:
+		for idx := range v {
+			v[idx] = yamlValue(v[idx])
 		}
-		// If we've decided it's a subsection, recurse into it.
-		if setting.fieldType == fieldTypeSubsection {
-			subsection := make(map[string]yaml.Node)
-			err := value.Decode(&subsection)
-			if err != nil {
-				return err
-			}
-			err = c.parseYamlMap(setting.subsection, subsection)
-			if err != nil {
-				return err
-			}
-			continue
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = yamlValue(v[key])
 		}
-		v := reflect.New(setting.reflectType)
-		// Unmarshal the value into the pointer
-		err := value.Decode(v.Interface())
-		if err != nil {
-			return err
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, elem := range v {
+			m[fmt.Sprint(key)] = yamlValue(elem)
 		}
-		//debugf("%s: v is type %s, val %+v\n", key, reflect.TypeOf(v), v.Elem())
-		setting.reflectValue = v.Elem()
-		setting.valueSet = true
+		return m
 	}
-	return nil
+	return value
 }